@@ -2,18 +2,37 @@ package main
 
 import (
 	"context"
+	"flag"
 	"fmt"
 	"net/http"
 	"os"
 	"strconv"
 
 	"github.com/linode/linodego"
+	builder "github.com/linode/packer-builder-linode/linode"
 	"golang.org/x/oauth2"
 )
 
 func main() {
+	manifest := flag.String("manifest", "", "delete the images of a build recorded in this Packer manifest")
+	buildName := flag.String("build-name", "", "the build in -manifest to delete, the most recent one by default")
+	flag.Parse()
+
+	if *manifest != "" {
+		artifact, err := builder.ArtifactFromManifest(*manifest, *buildName, nil)
+		if err != nil {
+			panic(err)
+		}
+		if err := artifact.Destroy(); err != nil {
+			panic(err)
+		}
+
+		fmt.Println("deleted image", artifact.Id())
+		return
+	}
+
 	apiKey := os.Getenv("LINODE_TOKEN")
-	imageID, err := strconv.Atoi(os.Args[1])
+	imageID, err := strconv.Atoi(flag.Arg(0))
 
 	if err != nil {
 		panic(err)
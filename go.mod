@@ -1,6 +1,7 @@
 module github.com/linode/packer-builder-linode
 
 require (
+	github.com/aws/aws-sdk-go v1.16.24
 	github.com/hashicorp/packer v1.4.0
 	github.com/linode/linodego v0.7.1
 	github.com/mna/pigeon v1.0.0 // indirect
@@ -8,4 +9,5 @@ require (
 	golang.org/x/oauth2 v0.0.0-20190130055435-99b60b757ec1
 	golang.org/x/tools v0.0.0-20190418235243-4796d4bd3df0 // indirect
 	gopkg.in/resty.v1 v1.11.0 // indirect
+	gopkg.in/yaml.v2 v2.2.2
 )
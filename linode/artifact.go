@@ -2,9 +2,13 @@ package linode
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
+	"net/http"
+	"strings"
 
+	"github.com/hashicorp/packer/packer"
 	"github.com/linode/linodego"
 )
 
@@ -12,21 +16,186 @@ type Artifact struct {
 	ImageID    string
 	ImageLabel string
 
+	// StateData should store data such as the pinned SSH host key
+	StateData map[string]interface{}
+
+	// ExportFiles are the files the image was exported to
+	ExportFiles []string
+
 	Driver *linodego.Client
 }
 
 func (a Artifact) BuilderId() string { return BuilderID }
-func (a Artifact) Files() []string   { return nil }
+func (a Artifact) Files() []string   { return a.ExportFiles }
 func (a Artifact) Id() string        { return a.ImageID }
 
 func (a Artifact) String() string {
 	return fmt.Sprintf("Linode image: %s (%s)", a.ImageLabel, a.ImageID)
 }
 
-func (a Artifact) State(name string) interface{} { return nil }
+func (a Artifact) State(name string) interface{} {
+	return a.StateData[name]
+}
 
 func (a Artifact) Destroy() error {
+	return a.DestroyContext(context.Background())
+}
+
+// DestroyContext deletes the image, and the resources of the build that were
+// left behind, such as a StackScript that could not be deleted. Resources that
+// are already deleted are ignored, so that it can be called again after a
+// failure. If the artifact has no Driver, such as when it is read from a
+// manifest, the API is accessed with the credentials of the environment.
+// Deleting an image deletes it from every region it was replicated to, so its
+// copies go with it. linodego doesn't expose the regions of an image.
+func (a Artifact) DestroyContext(ctx context.Context) error {
+	client := a.Driver
+	if client == nil {
+		var err error
+		if client, err = newArtifactClient(nil); err != nil {
+			return err
+		}
+	}
+
 	log.Printf("Destroying image: %s (%s)", a.ImageID, a.ImageLabel)
-	err := a.Driver.DeleteImage(context.TODO(), a.ImageID)
-	return err
+	if err := client.DeleteImage(ctx, a.ImageID); err != nil && !isNotFound(err) {
+		return err
+	}
+
+	if id, ok := a.StateData["stackscript_id"].(int); ok {
+		log.Printf("Destroying StackScript: %d", id)
+		if err := client.DeleteStackscript(ctx, id); err != nil && !isNotFound(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// CompositeArtifact is the result of a build fanned out to several regions or
+// instance types, with an image for each.
+type CompositeArtifact struct {
+	Artifacts []*Artifact
+}
+
+func (a *CompositeArtifact) BuilderId() string { return BuilderID }
+func (a *CompositeArtifact) Files() []string {
+	var files []string
+	for _, artifact := range a.Artifacts {
+		files = append(files, artifact.ExportFiles...)
+	}
+	return files
+}
+
+// Id returns the regions and IDs of the images, such as
+// "us-east:private/1,eu-west:private/2".
+func (a *CompositeArtifact) Id() string {
+	ids := make([]string, len(a.Artifacts))
+	for i, artifact := range a.Artifacts {
+		ids[i] = fmt.Sprintf("%s:%s", artifact.StateData["region"], artifact.ImageID)
+	}
+	return strings.Join(ids, ",")
+}
+
+func (a *CompositeArtifact) String() string {
+	lines := []string{"Linode images:"}
+	for _, artifact := range a.Artifacts {
+		lines = append(lines, fmt.Sprintf("%s (%s, %s): %s",
+			artifact.ImageLabel, artifact.StateData["region"], artifact.StateData["instance_type"], artifact.ImageID))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// State returns the value of name for each image, by image ID.
+func (a *CompositeArtifact) State(name string) interface{} {
+	values := make(map[string]interface{})
+	for _, artifact := range a.Artifacts {
+		values[artifact.ImageID] = artifact.State(name)
+	}
+	return values
+}
+
+func (a *CompositeArtifact) Destroy() error {
+	return a.DestroyContext(context.Background())
+}
+
+// DestroyContext deletes all the images, even if deleting one of them fails.
+func (a *CompositeArtifact) DestroyContext(ctx context.Context) error {
+	var errs *packer.MultiError
+	for _, artifact := range a.Artifacts {
+		if err := artifact.DestroyContext(ctx); err != nil {
+			errs = packer.MultiErrorAppend(errs, fmt.Errorf("unable to delete image %s: %s", artifact.ImageID, err))
+		}
+	}
+	if errs != nil {
+		return errs
+	}
+	return nil
+}
+
+// ArtifactFromManifest returns the artifact of the named Linode build, or of
+// the most recent one if name is empty, recorded in the manifest written by
+// Packer's manifest post-processor at path. This allows deleting the images
+// of a build afterwards. The API is accessed with the credentials of c, or of
+// the environment if c is nil.
+func ArtifactFromManifest(path, name string, c *Config) (packer.Artifact, error) {
+	m, err := readManifest(path)
+	if err != nil {
+		return nil, err
+	}
+	id, err := m.imageID(name)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := newArtifactClient(c)
+	if err != nil {
+		return nil, err
+	}
+
+	// The ID of a fanned out build lists the region and ID of each image.
+	if !strings.Contains(id, ":") {
+		return &Artifact{ImageID: id, StateData: map[string]interface{}{}, Driver: client}, nil
+	}
+	images, err := parseCompositeID(id)
+	if err != nil {
+		return nil, err
+	}
+	composite := new(CompositeArtifact)
+	for _, image := range images {
+		composite.Artifacts = append(composite.Artifacts, &Artifact{
+			ImageID:   image.ImageID,
+			StateData: map[string]interface{}{"region": image.Region},
+			Driver:    client,
+		})
+	}
+	return composite, nil
+}
+
+// newArtifactClient returns a client of the API configured by c, or by the
+// environment if c is nil.
+func newArtifactClient(c *Config) (*linodego.Client, error) {
+	config := new(Config)
+	if c != nil {
+		*config = *c
+	}
+	if err := config.resolveToken(); err != nil {
+		return nil, err
+	}
+	if config.PersonalAccessToken == "" {
+		return nil, errors.New("no Linode token found to delete the artifact, set LINODE_TOKEN")
+	}
+	config.setAPIDefaults()
+
+	httpClient, err := newLinodeHTTPClient(config)
+	if err != nil {
+		return nil, err
+	}
+	client := newLinodeClient(config, httpClient)
+	return &client, nil
+}
+
+// isNotFound reports whether err is the API error of a missing resource.
+func isNotFound(err error) bool {
+	apiErr, ok := err.(*linodego.Error)
+	return ok && apiErr.Code == http.StatusNotFound
 }
@@ -1,9 +1,13 @@
 package linode
 
 import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/hashicorp/packer/packer"
+	"github.com/linode/linodego"
 )
 
 func TestArtifact_Impl(t *testing.T) {
@@ -15,7 +19,7 @@ func TestArtifact_Impl(t *testing.T) {
 }
 
 func TestArtifactId(t *testing.T) {
-	a := &Artifact{"private/42", "packer-foobar", nil}
+	a := &Artifact{ImageID: "private/42", ImageLabel: "packer-foobar"}
 	expected := "private/42"
 
 	if a.Id() != expected {
@@ -24,10 +28,120 @@ func TestArtifactId(t *testing.T) {
 }
 
 func TestArtifactString(t *testing.T) {
-	a := &Artifact{"private/42", "packer-foobar", nil}
+	a := &Artifact{ImageID: "private/42", ImageLabel: "packer-foobar"}
 	expected := "Linode image: packer-foobar (private/42)"
 
 	if a.String() != expected {
 		t.Fatalf("artifact string should match: %v", expected)
 	}
 }
+
+func TestArtifactState_StateData(t *testing.T) {
+	expected := "ssh-rsa AAAA"
+	a := &Artifact{
+		StateData: map[string]interface{}{"ssh_host_key": expected},
+	}
+
+	if result := a.State("ssh_host_key"); result != expected {
+		t.Fatalf("bad: %#v", result)
+	}
+
+	if result := a.State("invalid_key"); result != nil {
+		t.Fatalf("bad: %#v", result)
+	}
+
+	a = &Artifact{}
+	if result := a.State("ssh_host_key"); result != nil {
+		t.Fatalf("bad: %#v", result)
+	}
+}
+
+func TestCompositeArtifact(t *testing.T) {
+	var raw interface{} = &CompositeArtifact{}
+	if _, ok := raw.(packer.Artifact); !ok {
+		t.Fatalf("CompositeArtifact should be artifact")
+	}
+
+	a := &CompositeArtifact{Artifacts: []*Artifact{
+		{
+			ImageID:    "private/42",
+			ImageLabel: "packer-foobar",
+			StateData:  map[string]interface{}{"region": "us-east", "instance_type": "g6-nanode-1"},
+		},
+		{
+			ImageID:    "private/43",
+			ImageLabel: "packer-foobar",
+			StateData:  map[string]interface{}{"region": "eu-west", "instance_type": "g6-nanode-1"},
+		},
+	}}
+
+	if id := a.Id(); id != "us-east:private/42,eu-west:private/43" {
+		t.Errorf("bad id: %s", id)
+	}
+
+	expected := "Linode images:\n" +
+		"packer-foobar (us-east, g6-nanode-1): private/42\n" +
+		"packer-foobar (eu-west, g6-nanode-1): private/43"
+	if s := a.String(); s != expected {
+		t.Errorf("bad string: %s", s)
+	}
+
+	state := a.State("region").(map[string]interface{})
+	if state["private/43"] != "eu-west" {
+		t.Errorf("bad state: %#v", state)
+	}
+}
+
+func TestArtifactFromManifest(t *testing.T) {
+	dir, err := ioutil.TempDir("", "packer-linode")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "manifest.json")
+	err = ioutil.WriteFile(path, []byte(`{
+  "builds": [
+    {"name": "base", "builder_type": "linode", "artifact_id": "private/42", "packer_run_uuid": "run-1"},
+    {"name": "app", "builder_type": "linode", "artifact_id": "us-east:private/43,eu-west:private/44", "packer_run_uuid": "run-1"}
+  ],
+  "last_run_uuid": "run-1"
+}`), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := &Config{PersonalAccessToken: "token"}
+
+	a, err := ArtifactFromManifest(path, "base", c)
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+	if artifact, ok := a.(*Artifact); !ok || artifact.Id() != "private/42" || artifact.Driver == nil {
+		t.Fatalf("bad: %#v", a)
+	}
+
+	a, err = ArtifactFromManifest(path, "app", c)
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+	composite, ok := a.(*CompositeArtifact)
+	if !ok || len(composite.Artifacts) != 2 {
+		t.Fatalf("bad: %#v", a)
+	}
+	if id := composite.Artifacts[1].ImageID; id != "private/44" {
+		t.Errorf("bad image ID: %s", id)
+	}
+	if region := composite.Artifacts[1].State("region"); region != "eu-west" {
+		t.Errorf("bad region: %v", region)
+	}
+}
+
+func TestIsNotFound(t *testing.T) {
+	if !isNotFound(&linodego.Error{Code: 404}) {
+		t.Error("404 should be not found")
+	}
+	if isNotFound(&linodego.Error{Code: 500}) {
+		t.Error("500 should not be not found")
+	}
+}
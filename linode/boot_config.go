@@ -0,0 +1,91 @@
+package linode
+
+import (
+	"context"
+	"errors"
+
+	"github.com/linode/linodego"
+)
+
+// BootHelpersOptions overrides the helpers of the configuration profile the
+// instance boots with. Helpers that are not set keep their default.
+type BootHelpersOptions struct {
+	Distro            *bool `mapstructure:"distro"`
+	ModulesDep        *bool `mapstructure:"modules_dep"`
+	Network           *bool `mapstructure:"network"`
+	DevTmpFsAutomount *bool `mapstructure:"devtmpfs_automount"`
+}
+
+// Empty reports whether no helper is overridden.
+func (h *BootHelpersOptions) Empty() bool {
+	return h.Distro == nil && h.ModulesDep == nil && h.Network == nil && h.DevTmpFsAutomount == nil
+}
+
+// apply returns the helpers with the overrides applied.
+func (h *BootHelpersOptions) apply(helpers *linodego.InstanceConfigHelpers) *linodego.InstanceConfigHelpers {
+	applied := new(linodego.InstanceConfigHelpers)
+	if helpers != nil {
+		*applied = *helpers
+	}
+	for _, o := range []struct {
+		value *bool
+		field *bool
+	}{
+		{h.Distro, &applied.Distro},
+		{h.ModulesDep, &applied.ModulesDep},
+		{h.Network, &applied.Network},
+		{h.DevTmpFsAutomount, &applied.DevTmpFsAutomount},
+	} {
+		if o.value != nil {
+			*o.field = *o.value
+		}
+	}
+	return applied
+}
+
+// customBoot reports whether the configuration profile of the instance has
+// to be changed before it is booted.
+func (c *Config) customBoot() bool {
+	return c.Kernel != "" || c.RunLevel != "" || !c.BootHelpers.Empty()
+}
+
+// configureBoot applies kernel, run_level and boot_helpers to the
+// configuration profile of an instance created without booting it, and then
+// boots it.
+func configureBoot(ctx context.Context, client linodego.Client, c *Config, instanceID int) error {
+	if _, err := client.WaitForInstanceStatus(ctx, instanceID, linodego.InstanceOffline, int(c.stateTimeout.Seconds())); err != nil {
+		return err
+	}
+
+	configs, err := client.ListInstanceConfigs(ctx, instanceID, nil)
+	if err != nil {
+		return err
+	}
+	if len(configs) == 0 {
+		return errors.New("no configuration profile was found")
+	}
+	profile := configs[0]
+
+	updateOpts := linodego.InstanceConfigUpdateOptions{
+		Label:       profile.Label,
+		Comments:    profile.Comments,
+		Devices:     profile.Devices,
+		MemoryLimit: profile.MemoryLimit,
+		Kernel:      profile.Kernel,
+		RootDevice:  profile.RootDevice,
+		RunLevel:    profile.RunLevel,
+		VirtMode:    profile.VirtMode,
+		Helpers:     c.BootHelpers.apply(profile.Helpers),
+	}
+	if c.Kernel != "" {
+		updateOpts.Kernel = c.Kernel
+	}
+	if c.RunLevel != "" {
+		updateOpts.RunLevel = c.RunLevel
+	}
+
+	if _, err := client.UpdateInstanceConfig(ctx, instanceID, profile.ID, updateOpts); err != nil {
+		return err
+	}
+	return client.BootInstance(ctx, instanceID, profile.ID)
+}
@@ -0,0 +1,30 @@
+package linode
+
+import (
+	"testing"
+
+	"github.com/linode/linodego"
+)
+
+func TestBootHelpersOptionsApply(t *testing.T) {
+	disabled, enabled := false, true
+	h := &BootHelpersOptions{Distro: &disabled, DevTmpFsAutomount: &enabled}
+
+	helpers := h.apply(&linodego.InstanceConfigHelpers{
+		Distro:     true,
+		ModulesDep: true,
+		Network:    true,
+	})
+	expected := linodego.InstanceConfigHelpers{
+		ModulesDep:        true,
+		Network:           true,
+		DevTmpFsAutomount: true,
+	}
+	if *helpers != expected {
+		t.Fatalf("bad: %#v", helpers)
+	}
+
+	if helpers = h.apply(nil); helpers.Distro || !helpers.DevTmpFsAutomount {
+		t.Fatalf("bad: %#v", helpers)
+	}
+}
@@ -0,0 +1,105 @@
+package linode
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/packer/packer"
+)
+
+// logUi records the output of a build so that it can be uploaded once the
+// build is done.
+type logUi struct {
+	packer.Ui
+
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (u *logUi) Say(message string) {
+	u.record("==> " + message)
+	u.Ui.Say(message)
+}
+
+func (u *logUi) Message(message string) {
+	u.record("    " + message)
+	u.Ui.Message(message)
+}
+
+func (u *logUi) Error(message string) {
+	u.record("ERROR: " + message)
+	u.Ui.Error(message)
+}
+
+func (u *logUi) record(message string) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	fmt.Fprintf(&u.buf, "%s %s\n", time.Now().UTC().Format(time.RFC3339), message)
+}
+
+func (u *logUi) bytes() []byte {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return append([]byte(nil), u.buf.Bytes()...)
+}
+
+// buildManifest describes the outcome of a build, uploaded along with its log.
+type buildManifest struct {
+	BuildName     string                 `json:"build_name"`
+	BuildTime     int64                  `json:"build_time"`
+	Region        string                 `json:"region"`
+	InstanceType  string                 `json:"instance_type"`
+	InstanceLabel string                 `json:"instance_label"`
+	ImageID       string                 `json:"image_id,omitempty"`
+	ImageLabel    string                 `json:"image_label,omitempty"`
+	Files         []string               `json:"files,omitempty"`
+	State         map[string]interface{} `json:"state,omitempty"`
+	Error         string                 `json:"error,omitempty"`
+}
+
+func newBuildManifest(c *Config, artifact *Artifact, buildErr error) *buildManifest {
+	m := &buildManifest{
+		BuildName:     c.PackerBuildName,
+		BuildTime:     time.Now().Unix(),
+		Region:        c.Region,
+		InstanceType:  c.InstanceType,
+		InstanceLabel: c.Label,
+	}
+	if artifact != nil {
+		m.ImageID = artifact.ImageID
+		m.ImageLabel = artifact.ImageLabel
+		m.Files = artifact.ExportFiles
+		m.State = artifact.StateData
+	}
+	if buildErr != nil {
+		m.Error = buildErr.Error()
+	}
+	return m
+}
+
+// uploadBuildLogs uploads the log and the manifest of a build to the bucket
+// of object_storage. Failing to do so doesn't fail the build.
+func uploadBuildLogs(ctx context.Context, ui packer.Ui, c *Config, log *logUi, artifact *Artifact, buildErr error) {
+	manifest, err := json.MarshalIndent(newBuildManifest(c, artifact, buildErr), "", "  ")
+	if err != nil {
+		ui.Error("Unable to encode the build manifest: " + err.Error())
+		return
+	}
+
+	ui.Say(fmt.Sprintf("Uploading build log to %s...", c.ObjectStorage.url(c.objectStorageLogKey(""))))
+	for _, object := range []struct {
+		name, contentType string
+		data              []byte
+	}{
+		{"build.log", "text/plain", log.bytes()},
+		{"manifest.json", "application/json", manifest},
+	} {
+		if err := c.ObjectStorage.put(ctx, c.objectStorageLogKey(object.name), object.contentType, object.data); err != nil {
+			ui.Error(fmt.Sprintf("Unable to upload %s: %s", object.name, err))
+		}
+	}
+}
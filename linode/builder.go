@@ -4,11 +4,16 @@ package linode
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
-	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
 
 	"github.com/hashicorp/packer/common"
 	"github.com/linode/linodego"
+	"golang.org/x/crypto/ssh"
 
 	"github.com/hashicorp/packer/helper/communicator"
 	"github.com/hashicorp/packer/helper/multistep"
@@ -21,7 +26,6 @@ const BuilderID = "packer.linode"
 // Builder represents a Packer Builder.
 type Builder struct {
 	config *Config
-	runner multistep.Runner
 }
 
 func (b *Builder) Prepare(raws ...interface{}) ([]string, error) {
@@ -29,46 +33,204 @@ func (b *Builder) Prepare(raws ...interface{}) ([]string, error) {
 	if errs != nil {
 		return warnings, errs
 	}
+
 	b.config = c
-	return nil, nil
+
+	// The region and instance type of a source instance are only known once
+	// it is looked up during the build.
+	if c.APIValidation == "prepare" && c.SourceInstanceID == 0 {
+		httpClient, err := newLinodeHTTPClient(c)
+		if err != nil {
+			return nil, err
+		}
+		client := newLinodeClient(c, httpClient)
+
+		var errs *packer.MultiError
+		for _, target := range c.targets() {
+			ws, err := validateWithAPI(context.Background(), target, client, httpClient)
+			warnings = append(warnings, ws...)
+			if err != nil {
+				errs = packer.MultiErrorAppend(errs, err)
+			}
+		}
+		if errs != nil {
+			return warnings, errs
+		}
+		return warnings, nil
+	}
+	return warnings, nil
 }
 
-func (b *Builder) Run(ctx context.Context, ui packer.Ui, hook packer.Hook) (ret packer.Artifact, err error) {
+func (b *Builder) Run(ctx context.Context, ui packer.Ui, hook packer.Hook) (packer.Artifact, error) {
 	ui.Say("Running builder ...")
 
-	client := newLinodeClient(b.config.PersonalAccessToken)
+	targets := b.config.targets()
+	if len(targets) > 1 {
+		return runTargets(ctx, ui, hook, targets)
+	}
 
+	artifact, err := run(ctx, ui, hook, targets[0])
+	if err != nil {
+		return nil, err
+	}
+	return artifact, nil
+}
+
+// run runs the build of a single instance, and uploads its log to
+// object_storage if set.
+func run(ctx context.Context, ui packer.Ui, hook packer.Hook, config *Config) (*Artifact, error) {
+	if config.ObjectStorage.Empty() {
+		return build(ctx, ui, hook, config)
+	}
+
+	log := &logUi{Ui: ui}
+	artifact, err := build(ctx, log, hook, config)
+
+	// The log of a cancelled build is uploaded too.
+	uploadCtx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	uploadBuildLogs(uploadCtx, ui, config, log, artifact, err)
+
+	return artifact, err
+}
+
+// build runs the steps of the build of a single instance.
+func build(ctx context.Context, ui packer.Ui, hook packer.Hook, config *Config) (*Artifact, error) {
+	var metricsLog io.Writer
+	if config.MetricsLogPath != "" {
+		f, err := os.Create(config.MetricsLogPath)
+		if err != nil {
+			err = errors.New("Error creating metrics log: " + err.Error())
+			ui.Error(err.Error())
+			return nil, err
+		}
+		defer f.Close()
+		metricsLog = f
+	}
+	metrics := newBuildMetrics(metricsLog)
+	tracer := newTracer(config)
+	tracer.startBuild(config)
+
+	httpClient, err := newLinodeHTTPClient(config)
 	if err != nil {
 		ui.Error(err.Error())
 		return nil, err
 	}
+	httpClient.Transport = tracer.transport(metrics.transport(httpClient.Transport))
+	client := newLinodeClient(config, httpClient)
 
 	state := new(multistep.BasicStateBag)
-	state.Put("config", b.config)
+	state.Put("config", config)
 	state.Put("hook", hook)
 	state.Put("ui", ui)
 
-	steps := []multistep.Step{
+	connect := &communicator.StepConnect{
+		Config:    &config.Comm,
+		Host:      commHost,
+		SSHConfig: sshConfig(&config.Comm),
+	}
+
+	var steps []multistep.Step
+	if config.APIValidation != "off" {
+		steps = append(steps, &stepCheckPermissions{httpClient})
+	}
+	if config.SourceInstanceID != 0 {
+		steps = append(steps, &stepResolveSourceInstance{client})
+	} else {
+		if config.resolveImage() {
+			steps = append(steps, &stepResolveImage{client})
+		}
+		if config.APIValidation != "off" {
+			steps = append(steps, &stepValidateAPI{client, httpClient})
+		}
+	}
+	steps = append(steps,
+		&stepPreflight{client},
 		&StepCreateSSHKey{
-			Debug:        b.config.PackerDebug,
-			DebugKeyPath: fmt.Sprintf("linode_%s.pem", b.config.PackerBuildName),
-		},
-		&stepCreateLinode{client},
-		&communicator.StepConnect{
-			Config:    &b.config.Comm,
-			Host:      commHost,
-			SSHConfig: b.config.Comm.SSHConfigFunc(),
+			Debug:         config.PackerDebug,
+			DebugKeyPath:  config.debugKeyPath(),
+			KeepOnFailure: config.KeepInstanceOnFailure,
 		},
-		&common.StepProvision{},
+	)
+	if config.SSHHostKeySeed {
+		steps = append(steps, &stepSeedHostKey{client})
+	}
+	if config.SourceInstanceID != 0 {
+		steps = append(steps, &stepSourceInstance{client})
+	} else {
+		steps = append(steps, &stepCreateLinode{client})
+	}
+	if config.ConsoleLogPath != "" {
+		steps = append(steps, &stepCaptureConsole{
+			Source: newConsoleSource(config, client),
+			Path:   config.ConsoleLogPath,
+			Lines:  config.ConsoleLogLines,
+		})
+	}
+	if config.BootMode == "rescue" {
+		steps = append(steps, &stepBootRescue{client}, connect, &stepMountRescueDisk{})
+	} else {
+		steps = append(steps, connect)
+	}
+	if config.mountVolumes() {
+		steps = append(steps, &stepMountVolumes{})
+	}
+	presignURLs := len(config.ObjectStorageURLs) > 0 || len(config.ObjectStorageUploadURLs) > 0
+	if presignURLs {
+		steps = append(steps, &stepObjectStorageEnv{})
+	}
+	steps = append(steps, &common.StepProvision{})
+	if presignURLs {
+		steps = append(steps, &stepRemoveObjectStorageEnv{})
+	}
+	steps = append(steps,
 		&common.StepCleanupTempKeys{
-			Comm: &b.config.Comm,
+			Comm: &config.Comm,
 		},
+	)
+	if config.RemoveBuildCredentials {
+		steps = append(steps, &stepRemoveCredentials{})
+	}
+	if config.ImageSanitize {
+		steps = append(steps, &stepSanitizeImage{})
+	} else if config.SSHHostKeySeed {
+		steps = append(steps, &stepRemoveSeededHostKey{})
+	}
+	if config.BootMode == "rescue" {
+		steps = append(steps, &stepUnmountRescueDisk{})
+	}
+	if len(config.Volumes) > 0 {
+		steps = append(steps, &stepDetachVolumes{client})
+	}
+	steps = append(steps,
 		&stepShutdownLinode{client},
 		&stepCreateImage{client},
+	)
+	if config.exportImage() {
+		steps = append(steps,
+			&stepBootRescue{client},
+			&communicator.StepConnect{
+				Config:    &config.Comm,
+				Host:      commHost,
+				SSHConfig: sshConfig(&config.Comm),
+			},
+			&stepExportImage{},
+		)
+	}
+
+	if config.timeSteps() {
+		for i, step := range steps {
+			steps[i] = &timedStep{Step: step, metrics: metrics, tracer: tracer}
+		}
 	}
 
-	b.runner = common.NewRunner(steps, b.config.PackerConfig, ui)
-	b.runner.Run(ctx, state)
+	runner := common.NewRunner(steps, config.PackerConfig, ui)
+	runner.Run(ctx, state)
+
+	tracer.endBuild(state)
+	if err := tracer.export(context.Background()); err != nil {
+		ui.Message("Warning: unable to export the trace: " + err.Error())
+	}
 
 	if rawErr, ok := state.GetOk("error"); ok {
 		return nil, rawErr.(error)
@@ -88,10 +250,61 @@ func (b *Builder) Run(ctx context.Context, ui packer.Ui, hook packer.Hook) (ret
 	}
 
 	image := state.Get("image").(*linodego.Image)
-	artifact := Artifact{
+	artifact := &Artifact{
 		ImageLabel: image.Label,
 		ImageID:    image.ID,
-		Driver:     &client,
+		StateData: map[string]interface{}{
+			"source_image_id": config.Image,
+			"build_tag":       config.BuildTag,
+			"region":          config.Region,
+		},
+		Driver: &client,
+	}
+
+	if config.SourceInstanceID != 0 {
+		artifact.StateData["source_instance_id"] = config.SourceInstanceID
+	}
+
+	if summary := metrics.summary(); len(summary.Steps) > 0 {
+		if data, err := json.Marshal(summary); err == nil {
+			artifact.StateData["build_metrics"] = string(data)
+		}
+	}
+
+	if sourceImage, ok := state.GetOk("source_image"); ok && !sourceImage.(*linodego.Image).IsPublic {
+		artifact.StateData["parent_image_id"] = sourceImage.(*linodego.Image).ID
+		artifact.StateData["parent_image_label"] = sourceImage.(*linodego.Image).Label
+	}
+
+	if files, ok := state.GetOk("export_files"); ok {
+		artifact.ExportFiles = files.([]string)
+	}
+	if sum, ok := state.GetOk("export_sha256"); ok {
+		artifact.StateData["export_sha256"] = sum
+	}
+	if url, ok := state.GetOk("export_url"); ok {
+		artifact.StateData["export_url"] = url
+	}
+
+	// Resources whose cleanup failed are deleted with the image.
+	if stackscript, ok := state.GetOk("stackscript"); ok {
+		artifact.StateData["stackscript_id"] = stackscript.(*linodego.Stackscript).ID
+	}
+
+	if instanceType, ok := state.GetOk("instance_type"); ok {
+		artifact.StateData["instance_type"] = instanceType.(*linodego.LinodeType).ID
+		artifact.StateData["instance_class"] = string(instanceType.(*linodego.LinodeType).Class)
+	}
+
+	// In rescue mode the only host key seen is the one of the rescue system,
+	// which says nothing about the image.
+	hostKey, ok := state.GetOk("instance_ssh_host_key")
+	if !ok {
+		hostKey, ok = state.GetOk("ssh_host_key")
+	}
+	if ok && config.BootMode != "rescue" {
+		artifact.StateData["ssh_host_key"] = strings.TrimSpace(string(ssh.MarshalAuthorizedKey(hostKey.(ssh.PublicKey))))
+		artifact.StateData["ssh_host_key_fingerprint"] = ssh.FingerprintSHA256(hostKey.(ssh.PublicKey))
 	}
 
 	return artifact, nil
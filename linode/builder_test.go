@@ -1,8 +1,10 @@
 package linode
 
 import (
+	"os"
 	"strconv"
 	"testing"
+	"time"
 
 	"github.com/hashicorp/packer/packer"
 )
@@ -285,3 +287,922 @@ func TestBuilderPrepare_Label(t *testing.T) {
 	}
 
 }
+
+func TestBuilderPrepare_ConsoleLog(t *testing.T) {
+	var b Builder
+	config := testConfig()
+
+	// Test missing console source
+	config["console_log_path"] = "console.log"
+	warnings, err := b.Prepare(config)
+	if len(warnings) > 0 {
+		t.Fatalf("bad: %#v", warnings)
+	}
+	if err == nil {
+		t.Fatal("should have error")
+	}
+
+	// Test set with command source
+	config["console_log_command"] = "cat boot.log"
+	b = Builder{}
+	warnings, err = b.Prepare(config)
+	if len(warnings) > 0 {
+		t.Fatalf("bad: %#v", warnings)
+	}
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+
+	if b.config.ConsoleLogLines != 20 {
+		t.Errorf("found %d, expected 20", b.config.ConsoleLogLines)
+	}
+}
+
+func TestBuilderPrepare_DisablePasswordAuth(t *testing.T) {
+	var b Builder
+	config := testConfig()
+
+	// Test default
+	warnings, err := b.Prepare(config)
+	if len(warnings) > 0 {
+		t.Fatalf("bad: %#v", warnings)
+	}
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+
+	if b.config.Comm.SSHPassword != b.config.RootPass {
+		t.Errorf("ssh_password should default to root_pass")
+	}
+
+	// Test set
+	config["disable_password_auth"] = true
+	b = Builder{}
+	warnings, err = b.Prepare(config)
+	if len(warnings) > 0 {
+		t.Fatalf("bad: %#v", warnings)
+	}
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+
+	if b.config.RootPass == "" {
+		t.Errorf("root_pass should still be generated")
+	}
+	if b.config.Comm.SSHPassword != "" {
+		t.Errorf("ssh_password should not be set: %s", b.config.Comm.SSHPassword)
+	}
+}
+
+func TestBuilderPrepare_ImageSanitize(t *testing.T) {
+	var b Builder
+	config := testConfig()
+
+	// Test set
+	config["image_sanitize"] = true
+	config["image_sanitize_paths"] = []string{"/etc/app/credentials.json", "/var/log/app"}
+	warnings, err := b.Prepare(config)
+	if len(warnings) > 0 {
+		t.Fatalf("bad: %#v", warnings)
+	}
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+
+	// Test bad paths
+	for _, p := range []string{"relative/path", "/it's"} {
+		config["image_sanitize_paths"] = []string{p}
+		b = Builder{}
+		warnings, err = b.Prepare(config)
+		if len(warnings) > 0 {
+			t.Fatalf("bad: %#v", warnings)
+		}
+		if err == nil {
+			t.Fatalf("should have error for %s", p)
+		}
+	}
+
+	// Test missing script
+	delete(config, "image_sanitize_paths")
+	config["image_sanitize_script"] = "/i/dont/exist"
+	b = Builder{}
+	warnings, err = b.Prepare(config)
+	if len(warnings) > 0 {
+		t.Fatalf("bad: %#v", warnings)
+	}
+	if err == nil {
+		t.Fatal("should have error")
+	}
+}
+
+func TestBuilderPrepare_ShutdownTimeout(t *testing.T) {
+	var b Builder
+	config := testConfig()
+
+	// Test default
+	warnings, err := b.Prepare(config)
+	if len(warnings) > 0 {
+		t.Fatalf("bad: %#v", warnings)
+	}
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+
+	if b.config.shutdownTimeout != 5*time.Minute {
+		t.Errorf("found %s, expected 5m", b.config.shutdownTimeout)
+	}
+
+	// Test set
+	config["shutdown_command"] = "shutdown -P now"
+	config["shutdown_timeout"] = "10m"
+	b = Builder{}
+	warnings, err = b.Prepare(config)
+	if len(warnings) > 0 {
+		t.Fatalf("bad: %#v", warnings)
+	}
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+
+	if b.config.shutdownTimeout != 10*time.Minute {
+		t.Errorf("found %s, expected 10m", b.config.shutdownTimeout)
+	}
+
+	// Test bad
+	config["shutdown_timeout"] = "tubes"
+	b = Builder{}
+	warnings, err = b.Prepare(config)
+	if len(warnings) > 0 {
+		t.Fatalf("bad: %#v", warnings)
+	}
+	if err == nil {
+		t.Fatal("should have error")
+	}
+}
+
+func TestBuilderPrepare_APIValidation(t *testing.T) {
+	var b Builder
+	config := testConfig()
+
+	// Test default
+	warnings, err := b.Prepare(config)
+	if len(warnings) > 0 {
+		t.Fatalf("bad: %#v", warnings)
+	}
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+
+	if b.config.APIValidation != "build" {
+		t.Errorf("found %s, expected build", b.config.APIValidation)
+	}
+
+	// Test bad
+	config["api_validation"] = "sometimes"
+	b = Builder{}
+	warnings, err = b.Prepare(config)
+	if len(warnings) > 0 {
+		t.Fatalf("bad: %#v", warnings)
+	}
+	if err == nil {
+		t.Fatal("should have error")
+	}
+}
+
+func TestBuilderPrepare_ImageFilter(t *testing.T) {
+	var b Builder
+	config := testConfig()
+
+	// Test both set
+	config["image_filter"] = map[string]interface{}{
+		"label":       "^base-",
+		"most_recent": true,
+	}
+	warnings, err := b.Prepare(config)
+	if len(warnings) > 0 {
+		t.Fatalf("bad: %#v", warnings)
+	}
+	if err == nil {
+		t.Fatal("should have error")
+	}
+
+	// Test set
+	delete(config, "image")
+	b = Builder{}
+	warnings, err = b.Prepare(config)
+	if len(warnings) > 0 {
+		t.Fatalf("bad: %#v", warnings)
+	}
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+
+	if b.config.ImageFilter.Label != "^base-" || !b.config.ImageFilter.MostRecent {
+		t.Errorf("bad: %#v", b.config.ImageFilter)
+	}
+
+	// Test bad regular expression
+	config["image_filter"] = map[string]interface{}{
+		"label": "base-(",
+	}
+	b = Builder{}
+	warnings, err = b.Prepare(config)
+	if len(warnings) > 0 {
+		t.Fatalf("bad: %#v", warnings)
+	}
+	if err == nil {
+		t.Fatal("should have error")
+	}
+}
+
+func TestBuilderPrepare_SourceImage(t *testing.T) {
+	var b Builder
+	config := testConfig()
+
+	// Test more than one source
+	config["source_build_tag"] = "base"
+	warnings, err := b.Prepare(config)
+	if len(warnings) > 0 {
+		t.Fatalf("bad: %#v", warnings)
+	}
+	if err == nil {
+		t.Fatal("should have error")
+	}
+
+	// Test build tag
+	delete(config, "image")
+	b = Builder{}
+	warnings, err = b.Prepare(config)
+	if len(warnings) > 0 {
+		t.Fatalf("bad: %#v", warnings)
+	}
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+
+	// Test manifest
+	delete(config, "source_build_tag")
+	config["source_image_from_manifest"] = "manifest.json"
+	config["source_manifest_build_name"] = "base"
+	config["build_tag"] = "runtime"
+	b = Builder{}
+	warnings, err = b.Prepare(config)
+	if len(warnings) > 0 {
+		t.Fatalf("bad: %#v", warnings)
+	}
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+
+	// Test bad build tag
+	config["build_tag"] = "run time"
+	b = Builder{}
+	warnings, err = b.Prepare(config)
+	if len(warnings) > 0 {
+		t.Fatalf("bad: %#v", warnings)
+	}
+	if err == nil {
+		t.Fatal("should have error")
+	}
+}
+
+func TestBuilderPrepare_ImageLabelBuildVariables(t *testing.T) {
+	var b Builder
+	config := testConfig()
+
+	// Test set with build variables
+	config["image_label"] = "{{ .Region }}-{{ .InstanceType }}"
+	config["image_description"] = "Built from {{ .SourceImage }}"
+	warnings, err := b.Prepare(config)
+	if len(warnings) > 0 {
+		t.Fatalf("bad: %#v", warnings)
+	}
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+
+	if b.config.ImageLabel != "us-east-g6-nanode-1" {
+		t.Errorf("bad: %s", b.config.ImageLabel)
+	}
+	if b.config.Description != "Built from linode/alpine3.9" {
+		t.Errorf("bad: %s", b.config.Description)
+	}
+
+	// Test bad metadata format
+	config["image_description_metadata"] = "xml"
+	b = Builder{}
+	warnings, err = b.Prepare(config)
+	if len(warnings) > 0 {
+		t.Fatalf("bad: %#v", warnings)
+	}
+	if err == nil {
+		t.Fatal("should have error")
+	}
+}
+
+func TestBuilderPrepare_BootConfig(t *testing.T) {
+	var b Builder
+	config := testConfig()
+
+	// Test default
+	warnings, err := b.Prepare(config)
+	if len(warnings) > 0 {
+		t.Fatalf("bad: %#v", warnings)
+	}
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+
+	if b.config.customBoot() {
+		t.Error("boot should not be customized by default")
+	}
+
+	// Test set
+	config["kernel"] = "linode/grub2"
+	config["run_level"] = "single"
+	config["boot_helpers"] = map[string]interface{}{
+		"distro": false,
+	}
+	b = Builder{}
+	warnings, err = b.Prepare(config)
+	if len(warnings) > 0 {
+		t.Fatalf("bad: %#v", warnings)
+	}
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+
+	if !b.config.customBoot() {
+		t.Error("boot should be customized")
+	}
+	if b.config.BootHelpers.Distro == nil || *b.config.BootHelpers.Distro {
+		t.Errorf("bad: %#v", b.config.BootHelpers)
+	}
+
+	// Test bad
+	config["run_level"] = "multiuser"
+	b = Builder{}
+	warnings, err = b.Prepare(config)
+	if len(warnings) > 0 {
+		t.Fatalf("bad: %#v", warnings)
+	}
+	if err == nil {
+		t.Fatal("should have error")
+	}
+}
+
+func TestBuilderPrepare_BootMode(t *testing.T) {
+	var b Builder
+	config := testConfig()
+
+	// Test default
+	warnings, err := b.Prepare(config)
+	if len(warnings) > 0 {
+		t.Fatalf("bad: %#v", warnings)
+	}
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+
+	if b.config.BootMode != "normal" {
+		t.Errorf("found %s, expected normal", b.config.BootMode)
+	}
+
+	// Test rescue without a Lish key
+	config["boot_mode"] = "rescue"
+	b = Builder{}
+	warnings, err = b.Prepare(config)
+	if len(warnings) > 0 {
+		t.Fatalf("bad: %#v", warnings)
+	}
+	if err == nil {
+		t.Fatal("should have error")
+	}
+
+	// Test rescue
+	config["lish_ssh_key_file"] = "lish_key"
+	b = Builder{}
+	warnings, err = b.Prepare(config)
+	if len(warnings) > 0 {
+		t.Fatalf("bad: %#v", warnings)
+	}
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+
+	if b.config.RescueMountPath != "/mnt/packer" {
+		t.Errorf("found %s, expected /mnt/packer", b.config.RescueMountPath)
+	}
+
+	// Test rescue with a custom kernel
+	config["kernel"] = "linode/grub2"
+	b = Builder{}
+	warnings, err = b.Prepare(config)
+	if len(warnings) > 0 {
+		t.Fatalf("bad: %#v", warnings)
+	}
+	if err == nil {
+		t.Fatal("should have error")
+	}
+
+	// Test bad
+	delete(config, "kernel")
+	config["boot_mode"] = "single"
+	b = Builder{}
+	warnings, err = b.Prepare(config)
+	if len(warnings) > 0 {
+		t.Fatalf("bad: %#v", warnings)
+	}
+	if err == nil {
+		t.Fatal("should have error")
+	}
+}
+
+func TestBuilderPrepare_KeepInstanceOnFailure(t *testing.T) {
+	var b Builder
+	config := testConfig()
+
+	// Test hold without keeping the instance
+	config["debug_hold_duration"] = "30m"
+	warnings, err := b.Prepare(config)
+	if len(warnings) > 0 {
+		t.Fatalf("bad: %#v", warnings)
+	}
+	if err == nil {
+		t.Fatal("should have error")
+	}
+
+	// Test set
+	config["keep_instance_on_failure"] = true
+	b = Builder{}
+	warnings, err = b.Prepare(config)
+	if len(warnings) > 0 {
+		t.Fatalf("bad: %#v", warnings)
+	}
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+
+	if b.config.debugHoldDuration != 30*time.Minute {
+		t.Errorf("found %s, expected 30m", b.config.debugHoldDuration)
+	}
+
+	// Test bad
+	config["debug_hold_duration"] = "tubes"
+	b = Builder{}
+	warnings, err = b.Prepare(config)
+	if len(warnings) > 0 {
+		t.Fatalf("bad: %#v", warnings)
+	}
+	if err == nil {
+		t.Fatal("should have error")
+	}
+}
+
+func TestBuilderPrepare_API(t *testing.T) {
+	var b Builder
+	config := testConfig()
+
+	// Test default
+	warnings, err := b.Prepare(config)
+	if len(warnings) > 0 {
+		t.Fatalf("bad: %#v", warnings)
+	}
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+
+	if b.config.apiBaseURL() != "https://api.linode.com/v4" {
+		t.Errorf("bad: %s", b.config.apiBaseURL())
+	}
+
+	// Test environment
+	defer os.Setenv("LINODE_URL", os.Getenv("LINODE_URL"))
+	defer os.Setenv("LINODE_API_VERSION", os.Getenv("LINODE_API_VERSION"))
+	os.Setenv("LINODE_URL", "http://localhost:8080")
+	os.Setenv("LINODE_API_VERSION", "v4beta")
+	b = Builder{}
+	warnings, err = b.Prepare(config)
+	if len(warnings) > 0 {
+		t.Fatalf("bad: %#v", warnings)
+	}
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+
+	if b.config.apiBaseURL() != "http://localhost:8080/v4beta" {
+		t.Errorf("bad: %s", b.config.apiBaseURL())
+	}
+
+	// Test set
+	config["api_url"] = "https://linode-api.example.com/"
+	config["api_version"] = "v4"
+	b = Builder{}
+	warnings, err = b.Prepare(config)
+	if len(warnings) > 0 {
+		t.Fatalf("bad: %#v", warnings)
+	}
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+
+	if b.config.apiBaseURL() != "https://linode-api.example.com/v4" {
+		t.Errorf("bad: %s", b.config.apiBaseURL())
+	}
+
+	// Test bad
+	config["api_version"] = "v3"
+	config["api_ca_file"] = "/i/dont/exist"
+	b = Builder{}
+	warnings, err = b.Prepare(config)
+	if len(warnings) > 0 {
+		t.Fatalf("bad: %#v", warnings)
+	}
+	if err == nil {
+		t.Fatal("should have error")
+	}
+}
+
+func TestBuilderPrepare_Preflight(t *testing.T) {
+	var b Builder
+	config := testConfig()
+
+	// Test default
+	warnings, err := b.Prepare(config)
+	if len(warnings) > 0 {
+		t.Fatalf("bad: %#v", warnings)
+	}
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+
+	if b.config.expectedBuildDuration != time.Hour {
+		t.Errorf("found %s, expected 1h", b.config.expectedBuildDuration)
+	}
+	if b.config.ImageQuotaCheck != "warn" {
+		t.Errorf("found %s, expected warn", b.config.ImageQuotaCheck)
+	}
+	if b.config.checkImageQuota() {
+		t.Error("image limits should not be checked unless they are set")
+	}
+
+	// Test set
+	config["expected_build_duration"] = "20m"
+	config["max_build_cost"] = 0.05
+	config["image_quota_check"] = "fail"
+	config["image_storage_limit"] = 10240
+	b = Builder{}
+	warnings, err = b.Prepare(config)
+	if len(warnings) > 0 {
+		t.Fatalf("bad: %#v", warnings)
+	}
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+
+	if b.config.expectedBuildDuration != 20*time.Minute {
+		t.Errorf("found %s, expected 20m", b.config.expectedBuildDuration)
+	}
+	if b.config.MaxBuildCost != 0.05 {
+		t.Errorf("found %v, expected 0.05", b.config.MaxBuildCost)
+	}
+	if !b.config.checkImageQuota() {
+		t.Error("image limits should be checked")
+	}
+
+	// Test bad
+	config["image_quota_check"] = "abort"
+	b = Builder{}
+	warnings, err = b.Prepare(config)
+	if len(warnings) > 0 {
+		t.Fatalf("bad: %#v", warnings)
+	}
+	if err == nil {
+		t.Fatal("should have error")
+	}
+}
+
+func TestBuilderPrepare_Regions(t *testing.T) {
+	var b Builder
+	config := testConfig()
+
+	// Test set
+	delete(config, "region")
+	config["regions"] = []string{"us-east", "eu-west"}
+	delete(config, "instance_type")
+	config["instance_types"] = []string{"g6-nanode-1", "g6-standard-1"}
+	warnings, err := b.Prepare(config)
+	if len(warnings) > 0 {
+		t.Fatalf("bad: %#v", warnings)
+	}
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+
+	if targets := b.config.targets(); len(targets) != 4 {
+		t.Errorf("found %d targets, expected 4", len(targets))
+	}
+
+	// Test bad
+	config["region"] = "us-east"
+	b = Builder{}
+	warnings, err = b.Prepare(config)
+	if len(warnings) > 0 {
+		t.Fatalf("bad: %#v", warnings)
+	}
+	if err == nil {
+		t.Fatal("should have error")
+	}
+
+	delete(config, "region")
+	config["regions"] = []string{"us-east", ""}
+	b = Builder{}
+	warnings, err = b.Prepare(config)
+	if len(warnings) > 0 {
+		t.Fatalf("bad: %#v", warnings)
+	}
+	if err == nil {
+		t.Fatal("should have error")
+	}
+
+	config["regions"] = []string{"us-east", "eu-west"}
+	config["packer_on_error"] = "ask"
+	b = Builder{}
+	warnings, err = b.Prepare(config)
+	if len(warnings) > 0 {
+		t.Fatalf("bad: %#v", warnings)
+	}
+	if err == nil {
+		t.Fatal("should have error")
+	}
+}
+
+func TestBuilderPrepare_Export(t *testing.T) {
+	var b Builder
+	config := testConfig()
+
+	// Test default
+	warnings, err := b.Prepare(config)
+	if len(warnings) > 0 {
+		t.Fatalf("bad: %#v", warnings)
+	}
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+
+	if b.config.exportImage() {
+		t.Error("image should not be exported")
+	}
+
+	// Test set
+	config["export_path"] = "image.img.gz"
+	config["export_object_storage"] = map[string]interface{}{
+		"cluster":    "us-east-1",
+		"bucket":     "images",
+		"access_key": "access",
+		"secret_key": "secret",
+	}
+	config["lish_ssh_key_file"] = "lish.pem"
+	b = Builder{}
+	warnings, err = b.Prepare(config)
+	if len(warnings) > 0 {
+		t.Fatalf("bad: %#v", warnings)
+	}
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+
+	if b.config.ExportChunkSize != 256 {
+		t.Errorf("found %d, expected 256", b.config.ExportChunkSize)
+	}
+	if endpoint := b.config.ExportObjectStorage.endpoint(); endpoint != "https://us-east-1.linodeobjects.com" {
+		t.Errorf("bad endpoint: %s", endpoint)
+	}
+
+	// Test bad
+	delete(config, "lish_ssh_key_file")
+	config["export_object_storage"] = map[string]interface{}{"bucket": "images"}
+	b = Builder{}
+	warnings, err = b.Prepare(config)
+	if len(warnings) > 0 {
+		t.Fatalf("bad: %#v", warnings)
+	}
+	if err == nil {
+		t.Fatal("should have error")
+	}
+}
+
+func TestBuilderPrepare_ObjectStorage(t *testing.T) {
+	var b Builder
+	config := testConfig()
+
+	// Test default
+	warnings, err := b.Prepare(config)
+	if len(warnings) > 0 {
+		t.Fatalf("bad: %#v", warnings)
+	}
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+
+	if b.config.objectStorageURLTTL != time.Hour {
+		t.Errorf("found %s, expected 1h", b.config.objectStorageURLTTL)
+	}
+	if key := b.config.objectStorageLogKey("build.log"); key != "packer/"+b.config.Label+"/build.log" {
+		t.Errorf("bad log key: %s", key)
+	}
+
+	// Test set
+	os.Setenv("TEST_OBJ_ACCESS_KEY", "access")
+	os.Setenv("TEST_OBJ_SECRET_KEY", "secret")
+	defer os.Unsetenv("TEST_OBJ_ACCESS_KEY")
+	defer os.Unsetenv("TEST_OBJ_SECRET_KEY")
+	config["object_storage"] = map[string]interface{}{
+		"endpoint":       "http://127.0.0.1:9000",
+		"bucket":         "builds",
+		"access_key_env": "TEST_OBJ_ACCESS_KEY",
+		"secret_key_env": "TEST_OBJ_SECRET_KEY",
+	}
+	config["object_storage_urls"] = map[string]string{"APP_URL": "inputs/app.tar.gz"}
+	config["object_storage_url_ttl"] = "30m"
+	b = Builder{}
+	warnings, err = b.Prepare(config)
+	if len(warnings) > 0 {
+		t.Fatalf("bad: %#v", warnings)
+	}
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+
+	if b.config.ObjectStorage.AccessKey != "access" || b.config.ObjectStorage.SecretKey != "secret" {
+		t.Errorf("keys should be read from the environment: %#v", b.config.ObjectStorage)
+	}
+	if b.config.objectStorageURLTTL != 30*time.Minute {
+		t.Errorf("found %s, expected 30m", b.config.objectStorageURLTTL)
+	}
+
+	// Test bad
+	config["object_storage_urls"] = map[string]string{"APP-URL": "inputs/app.tar.gz"}
+	b = Builder{}
+	warnings, err = b.Prepare(config)
+	if len(warnings) > 0 {
+		t.Fatalf("bad: %#v", warnings)
+	}
+	if err == nil {
+		t.Fatal("should have error")
+	}
+
+	config["object_storage_urls"] = map[string]string{"APP_URL": "inputs/app.tar.gz"}
+	config["object_storage_url_ttl"] = "240h"
+	b = Builder{}
+	warnings, err = b.Prepare(config)
+	if len(warnings) > 0 {
+		t.Fatalf("bad: %#v", warnings)
+	}
+	if err == nil {
+		t.Fatal("should have error")
+	}
+}
+
+func TestBuilderPrepare_Volumes(t *testing.T) {
+	var b Builder
+	config := testConfig()
+
+	// Test set
+	config["volumes"] = []map[string]interface{}{
+		{"id": 42, "mount_path": "/var/cache/apt"},
+		{"size": 20, "label": "scratch"},
+	}
+	warnings, err := b.Prepare(config)
+	if len(warnings) > 0 {
+		t.Fatalf("bad: %#v", warnings)
+	}
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+
+	if len(b.config.Volumes) != 2 || b.config.Volumes[0].ID != 42 || b.config.Volumes[1].Size != 20 {
+		t.Errorf("bad volumes: %#v", b.config.Volumes)
+	}
+	if !b.config.mountVolumes() {
+		t.Error("volumes should be mounted")
+	}
+
+	// Test bad
+	config["regions"] = []string{"us-east", "eu-west"}
+	delete(config, "region")
+	b = Builder{}
+	warnings, err = b.Prepare(config)
+	if len(warnings) > 0 {
+		t.Fatalf("bad: %#v", warnings)
+	}
+	if err == nil {
+		t.Fatal("should have error")
+	}
+}
+
+func TestBuilderPrepare_SourceInstance(t *testing.T) {
+	var b Builder
+	config := testConfig()
+
+	// Test set
+	delete(config, "image")
+	delete(config, "region")
+	delete(config, "instance_type")
+	config["source_instance_id"] = 42
+	config["ssh_password"] = "hunter2"
+	warnings, err := b.Prepare(config)
+	if len(warnings) > 0 {
+		t.Fatalf("bad: %#v", warnings)
+	}
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+
+	if b.config.SourceInstanceID != 42 {
+		t.Errorf("found %d, expected 42", b.config.SourceInstanceID)
+	}
+	if b.config.Comm.SSHPassword != "hunter2" {
+		t.Errorf("the password of the instance should be kept: %s", b.config.Comm.SSHPassword)
+	}
+
+	config["source_instance_clone"] = true
+	config["region"] = "us-east"
+	config["image_sanitize"] = true
+	b = Builder{}
+	warnings, err = b.Prepare(config)
+	if len(warnings) > 0 {
+		t.Fatalf("bad: %#v", warnings)
+	}
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+
+	// Test bad
+	config["source_instance_clone"] = false
+	b = Builder{}
+	warnings, err = b.Prepare(config)
+	if len(warnings) > 0 {
+		t.Fatalf("bad: %#v", warnings)
+	}
+	if err == nil {
+		t.Fatal("should have error")
+	}
+
+	delete(config, "region")
+	delete(config, "image_sanitize")
+	config["image"] = "linode/debian9"
+	b = Builder{}
+	warnings, err = b.Prepare(config)
+	if len(warnings) > 0 {
+		t.Fatalf("bad: %#v", warnings)
+	}
+	if err == nil {
+		t.Fatal("should have error")
+	}
+
+	delete(config, "image")
+	delete(config, "ssh_password")
+	b = Builder{}
+	warnings, err = b.Prepare(config)
+	if len(warnings) > 0 {
+		t.Fatalf("bad: %#v", warnings)
+	}
+	if err == nil {
+		t.Fatal("should have error")
+	}
+}
+
+func TestBuilderPrepare_MetricsDebug(t *testing.T) {
+	var b Builder
+	config := testConfig()
+
+	// Test default
+	config["metrics_log_path"] = "metrics.jsonl"
+	warnings, err := b.Prepare(config)
+	if len(warnings) > 0 {
+		t.Fatalf("bad: %#v", warnings)
+	}
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+
+	if !b.config.timeSteps() {
+		t.Error("steps should be timed")
+	}
+
+	// Test set
+	config["packer_debug"] = true
+	b = Builder{}
+	warnings, err = b.Prepare(config)
+	if len(warnings) != 1 {
+		t.Fatalf("metrics_log_path should warn in debug mode: %#v", warnings)
+	}
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+
+	if b.config.timeSteps() {
+		t.Error("steps should not be timed in debug mode")
+	}
+}
@@ -0,0 +1,392 @@
+package linode
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/packer/packer"
+	"github.com/linode/linodego"
+)
+
+// catalogTTL is how long a catalog cached on disk is used before it is
+// fetched again.
+const catalogTTL = time.Hour
+
+// regionCapabilities maps plan classes to the region capability needed to
+// launch them.
+var regionCapabilities = map[string]string{
+	"gpu":     "GPU Linodes",
+	"premium": "Premium Plans",
+}
+
+type catalogRegion struct {
+	ID           string   `json:"id"`
+	Capabilities []string `json:"capabilities"`
+}
+
+type catalogType struct {
+	ID    string `json:"id"`
+	Class string `json:"class"`
+	Disk  int    `json:"disk"`
+}
+
+type catalogImage struct {
+	ID         string               `json:"id"`
+	Deprecated bool                 `json:"deprecated"`
+	Regions    []catalogImageRegion `json:"regions"`
+}
+
+// catalogImageRegion is a region a private image is stored in. Images the API
+// lists no regions for, such as public images, are available in every region.
+type catalogImageRegion struct {
+	Region string `json:"region"`
+	Status string `json:"status"`
+}
+
+// availableIn reports whether the image can be deployed in region.
+func (i *catalogImage) availableIn(region string) bool {
+	if len(i.Regions) == 0 {
+		return true
+	}
+	for _, r := range i.Regions {
+		if r.Region == region && r.Status == "available" {
+			return true
+		}
+	}
+	return false
+}
+
+type catalogKernel struct {
+	ID         string `json:"id"`
+	Deprecated bool   `json:"deprecated"`
+}
+
+// catalog holds what the API offers to the account: regions, plans, images
+// and kernels. It is cached on disk to keep validation fast.
+type catalog struct {
+	Fetched time.Time       `json:"fetched"`
+	Regions []catalogRegion `json:"regions"`
+	Types   []catalogType   `json:"types"`
+	Images  []catalogImage  `json:"images"`
+	Kernels []catalogKernel `json:"kernels"`
+}
+
+func (cat *catalog) region(id string) *catalogRegion {
+	for i := range cat.Regions {
+		if cat.Regions[i].ID == id {
+			return &cat.Regions[i]
+		}
+	}
+	return nil
+}
+
+func (cat *catalog) instanceType(id string) *catalogType {
+	for i := range cat.Types {
+		if cat.Types[i].ID == id {
+			return &cat.Types[i]
+		}
+	}
+	return nil
+}
+
+func (cat *catalog) image(id string) *catalogImage {
+	for i := range cat.Images {
+		if cat.Images[i].ID == id {
+			return &cat.Images[i]
+		}
+	}
+	return nil
+}
+
+func (cat *catalog) kernel(id string) *catalogKernel {
+	for i := range cat.Kernels {
+		if cat.Kernels[i].ID == id {
+			return &cat.Kernels[i]
+		}
+	}
+	return nil
+}
+
+// validate checks the configuration against the catalog, returning warnings
+// and errors.
+func (cat *catalog) validate(c *Config) ([]string, []error) {
+	var warnings []string
+	var errs []error
+
+	region := cat.region(c.Region)
+	if region == nil {
+		var ids []string
+		for _, r := range cat.Regions {
+			ids = append(ids, r.ID)
+		}
+		errs = append(errs, notFoundError("region", c.Region, ids))
+	}
+
+	instanceType := cat.instanceType(c.InstanceType)
+	if instanceType == nil {
+		var ids []string
+		for _, t := range cat.Types {
+			ids = append(ids, t.ID)
+		}
+		errs = append(errs, notFoundError("instance_type", c.InstanceType, ids))
+	}
+
+	if region != nil && instanceType != nil {
+		if capability, ok := regionCapabilities[instanceType.Class]; ok && !hasString(region.Capabilities, capability) {
+			errs = append(errs, fmt.Errorf("instance_type %q is not available in region %q",
+				c.InstanceType, c.Region))
+		}
+	}
+
+	for _, id := range c.TargetInstanceTypes {
+		target := cat.instanceType(id)
+		if target == nil {
+			var ids []string
+			for _, t := range cat.Types {
+				ids = append(ids, t.ID)
+			}
+			errs = append(errs, notFoundError("target_instance_types entry", id, ids))
+			continue
+		}
+		if instanceType == nil {
+			continue
+		}
+		if instanceType.Disk > target.Disk {
+			warnings = append(warnings, fmt.Sprintf(
+				"the disk of instance_type %q (%d MB) is larger than the disk of %q (%d MB), the image may not fit on it",
+				c.InstanceType, instanceType.Disk, id, target.Disk))
+		}
+		if instanceType.Class != target.Class {
+			warnings = append(warnings, fmt.Sprintf(
+				"instance_type %q is a %s plan but %q is a %s plan, drivers and kernel modules may not match",
+				c.InstanceType, instanceType.Class, id, target.Class))
+		}
+	}
+
+	if c.Image != "" {
+		if image := cat.image(c.Image); image == nil {
+			var ids []string
+			for _, i := range cat.Images {
+				ids = append(ids, i.ID)
+			}
+			errs = append(errs, notFoundError("image", c.Image, ids))
+		} else {
+			if image.Deprecated {
+				warnings = append(warnings, fmt.Sprintf("image %q is deprecated", c.Image))
+			}
+			if region != nil && !image.availableIn(c.Region) {
+				var regions []string
+				for _, r := range image.Regions {
+					regions = append(regions, fmt.Sprintf("%s (%s)", r.Region, r.Status))
+				}
+				errs = append(errs, fmt.Errorf("image %q is not available in region %q, only in %s",
+					c.Image, c.Region, strings.Join(regions, ", ")))
+			}
+		}
+	}
+
+	if c.Kernel != "" {
+		if kernel := cat.kernel(c.Kernel); kernel == nil {
+			var ids []string
+			for _, k := range cat.Kernels {
+				ids = append(ids, k.ID)
+			}
+			errs = append(errs, notFoundError("kernel", c.Kernel, ids))
+		} else if kernel.Deprecated {
+			warnings = append(warnings, fmt.Sprintf("kernel %q is deprecated", c.Kernel))
+		}
+	}
+
+	return warnings, errs
+}
+
+// validateWithAPI validates the configuration against the catalog. A cached
+// catalog is refreshed once if something can't be found in it, as it may
+// simply be outdated.
+func validateWithAPI(ctx context.Context, c *Config, client linodego.Client, httpClient *http.Client) ([]string, error) {
+	cat, err := loadCatalog(ctx, c, client, httpClient, false)
+	if err != nil {
+		return nil, err
+	}
+
+	warnings, errs := cat.validate(c)
+	if len(errs) > 0 && time.Since(cat.Fetched) > time.Minute {
+		if cat, err = loadCatalog(ctx, c, client, httpClient, true); err != nil {
+			return nil, err
+		}
+		warnings, errs = cat.validate(c)
+	}
+
+	if len(errs) > 0 {
+		return warnings, &packer.MultiError{Errors: errs}
+	}
+	return warnings, nil
+}
+
+// loadCatalog returns the cached catalog if it is recent enough, or fetches
+// and caches it otherwise.
+func loadCatalog(ctx context.Context, c *Config, client linodego.Client, httpClient *http.Client, refresh bool) (*catalog, error) {
+	path := catalogPath(c)
+
+	if !refresh && path != "" {
+		if data, err := ioutil.ReadFile(path); err == nil {
+			cat := new(catalog)
+			if err := json.Unmarshal(data, cat); err == nil && time.Since(cat.Fetched) < catalogTTL {
+				return cat, nil
+			}
+		}
+	}
+
+	cat, err := fetchCatalog(ctx, c, client, httpClient)
+	if err != nil {
+		return nil, err
+	}
+
+	if path != "" {
+		if err := saveCatalog(path, cat); err != nil {
+			log.Printf("Unable to cache the Linode catalog: %s", err)
+		}
+	}
+
+	return cat, nil
+}
+
+func saveCatalog(path string, cat *catalog) error {
+	data, err := json.Marshal(cat)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, data, 0600)
+}
+
+func fetchCatalog(ctx context.Context, c *Config, client linodego.Client, httpClient *http.Client) (*catalog, error) {
+	cat := &catalog{Fetched: time.Now()}
+
+	// linodego doesn't expose region capabilities.
+	var regions struct {
+		Data []catalogRegion `json:"data"`
+	}
+	if err := getJSON(ctx, httpClient, c.apiBaseURL(), "/regions?page_size=500", &regions); err != nil {
+		return nil, fmt.Errorf("Error listing regions: %s", err)
+	}
+	cat.Regions = regions.Data
+
+	types, err := client.ListTypes(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("Error listing instance types: %s", err)
+	}
+	for _, t := range types {
+		cat.Types = append(cat.Types, catalogType{
+			ID:    t.ID,
+			Class: string(t.Class),
+			Disk:  t.Disk,
+		})
+	}
+
+	// linodego doesn't expose the regions of an image.
+	for page := 1; ; page++ {
+		var images struct {
+			Data  []catalogImage `json:"data"`
+			Pages int            `json:"pages"`
+		}
+		if err := getJSON(ctx, httpClient, c.apiBaseURL(), fmt.Sprintf("/images?page=%d&page_size=500", page), &images); err != nil {
+			return nil, fmt.Errorf("Error listing images: %s", err)
+		}
+		cat.Images = append(cat.Images, images.Data...)
+		if page >= images.Pages {
+			break
+		}
+	}
+
+	// linodego doesn't expose whether a kernel is deprecated.
+	var kernels struct {
+		Data []catalogKernel `json:"data"`
+	}
+	if err := getJSON(ctx, httpClient, c.apiBaseURL(), "/linode/kernels?page_size=500", &kernels); err != nil {
+		return nil, fmt.Errorf("Error listing kernels: %s", err)
+	}
+	cat.Kernels = kernels.Data
+
+	return cat, nil
+}
+
+// catalogPath returns where the catalog is cached. The catalog includes
+// private images, so it is cached per token and API.
+func catalogPath(c *Config) string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(c.apiBaseURL() + "\n" + c.PersonalAccessToken))
+	return filepath.Join(dir, "packer-builder-linode", fmt.Sprintf("catalog-%x.json", sum[:8]))
+}
+
+func notFoundError(key, value string, candidates []string) error {
+	if suggestion := suggest(value, candidates); suggestion != "" {
+		return fmt.Errorf("%s %q does not exist, did you mean %q?", key, value, suggestion)
+	}
+	return fmt.Errorf("%s %q does not exist", key, value)
+}
+
+// suggest returns the candidate closest to value, if any is close enough to
+// be a likely typo.
+func suggest(value string, candidates []string) string {
+	best, bestDistance := "", len(value)/3+2
+	for _, candidate := range candidates {
+		if d := levenshtein(strings.ToLower(value), strings.ToLower(candidate)); d < bestDistance {
+			best, bestDistance = candidate, d
+		}
+	}
+	return best
+}
+
+func levenshtein(a, b string) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = minInt(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
+
+func minInt(values ...int) int {
+	m := values[0]
+	for _, v := range values[1:] {
+		if v < m {
+			m = v
+		}
+	}
+	return m
+}
+
+func hasString(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,150 @@
+package linode
+
+import (
+	"strings"
+	"testing"
+)
+
+func testCatalog() *catalog {
+	return &catalog{
+		Regions: []catalogRegion{
+			{ID: "us-east", Capabilities: []string{"Linodes", "GPU Linodes"}},
+			{ID: "us-central", Capabilities: []string{"Linodes"}},
+		},
+		Types: []catalogType{
+			{ID: "g6-nanode-1", Class: "nanode", Disk: 25600},
+			{ID: "g6-dedicated-2", Class: "dedicated", Disk: 81920},
+			{ID: "g6-dedicated-4", Class: "dedicated", Disk: 163840},
+			{ID: "g1-gpu-rtx6000-1", Class: "gpu", Disk: 655360},
+		},
+		Images: []catalogImage{
+			{ID: "linode/alpine3.9"},
+			{ID: "linode/debian8", Deprecated: true},
+			{ID: "private/1", Regions: []catalogImageRegion{
+				{Region: "us-east", Status: "available"},
+				{Region: "us-central", Status: "pending replication"},
+			}},
+		},
+		Kernels: []catalogKernel{
+			{ID: "linode/grub2"},
+			{ID: "linode/direct-disk"},
+			{ID: "linode/4.9.15-x86_64-linode81", Deprecated: true},
+		},
+	}
+}
+
+func TestCatalogValidate(t *testing.T) {
+	cat := testCatalog()
+	c := &Config{Region: "us-east", InstanceType: "g6-nanode-1", Image: "linode/alpine3.9"}
+
+	warnings, errs := cat.validate(c)
+	if len(warnings) > 0 || len(errs) > 0 {
+		t.Fatalf("bad: %#v %#v", warnings, errs)
+	}
+
+	c.Image = "linode/debian8"
+	warnings, errs = cat.validate(c)
+	if len(warnings) != 1 || len(errs) > 0 {
+		t.Fatalf("deprecated image should warn: %#v %#v", warnings, errs)
+	}
+
+	c.Image = "private/1"
+	warnings, errs = cat.validate(c)
+	if len(warnings) > 0 || len(errs) > 0 {
+		t.Fatalf("bad: %#v %#v", warnings, errs)
+	}
+
+	c.Region = "us-central"
+	_, errs = cat.validate(c)
+	if len(errs) != 1 || !strings.Contains(errs[0].Error(), "not available in region") {
+		t.Fatalf("image should not be available in the region: %#v", errs)
+	}
+}
+
+func TestCatalogValidate_Suggestions(t *testing.T) {
+	cat := testCatalog()
+	c := &Config{Region: "us-eats", InstanceType: "g6-nanod-1", Image: "linode/alpine3.8"}
+
+	_, errs := cat.validate(c)
+	if len(errs) != 3 {
+		t.Fatalf("expected 3 errors: %#v", errs)
+	}
+
+	for i, expected := range []string{`"us-east"`, `"g6-nanode-1"`, `"linode/alpine3.9"`} {
+		if !strings.Contains(errs[i].Error(), "did you mean "+expected) {
+			t.Errorf("error should suggest %s: %s", expected, errs[i])
+		}
+	}
+}
+
+func TestCatalogValidate_RegionCapabilities(t *testing.T) {
+	cat := testCatalog()
+	c := &Config{Region: "us-east", InstanceType: "g1-gpu-rtx6000-1"}
+
+	if _, errs := cat.validate(c); len(errs) > 0 {
+		t.Fatalf("bad: %#v", errs)
+	}
+
+	c.Region = "us-central"
+	if _, errs := cat.validate(c); len(errs) != 1 {
+		t.Fatalf("gpu plans should not be available in us-central: %#v", errs)
+	}
+}
+
+func TestCatalogValidate_TargetInstanceTypes(t *testing.T) {
+	cat := testCatalog()
+	c := &Config{
+		Region:              "us-east",
+		InstanceType:        "g6-dedicated-2",
+		TargetInstanceTypes: []string{"g6-dedicated-4"},
+	}
+
+	if warnings, errs := cat.validate(c); len(warnings) > 0 || len(errs) > 0 {
+		t.Fatalf("bad: %#v %#v", warnings, errs)
+	}
+
+	c.TargetInstanceTypes = []string{"g6-nanode-1"}
+	warnings, errs := cat.validate(c)
+	if len(errs) > 0 {
+		t.Fatalf("bad: %#v", errs)
+	}
+	if len(warnings) != 2 {
+		t.Fatalf("expected disk and class warnings: %#v", warnings)
+	}
+
+	c.TargetInstanceTypes = []string{"g6-dedicated-8"}
+	if _, errs := cat.validate(c); len(errs) != 1 {
+		t.Fatalf("unknown target_instance_types should error: %#v", errs)
+	}
+}
+
+func TestCatalogValidate_Kernel(t *testing.T) {
+	cat := testCatalog()
+	c := &Config{Region: "us-east", InstanceType: "g6-nanode-1", Kernel: "linode/grub2"}
+
+	if warnings, errs := cat.validate(c); len(warnings) > 0 || len(errs) > 0 {
+		t.Fatalf("bad: %#v %#v", warnings, errs)
+	}
+
+	c.Kernel = "linode/4.9.15-x86_64-linode81"
+	if warnings, errs := cat.validate(c); len(warnings) != 1 || len(errs) > 0 {
+		t.Fatalf("deprecated kernel should warn: %#v %#v", warnings, errs)
+	}
+
+	c.Kernel = "linode/grub"
+	_, errs := cat.validate(c)
+	if len(errs) != 1 || !strings.Contains(errs[0].Error(), `did you mean "linode/grub2"`) {
+		t.Fatalf("bad: %#v", errs)
+	}
+}
+
+func TestSuggest(t *testing.T) {
+	candidates := []string{"us-east", "us-west", "eu-central"}
+
+	if s := suggest("US-East", candidates); s != "us-east" {
+		t.Errorf("bad: %s", s)
+	}
+	if s := suggest("ap-northeast", candidates); s != "" {
+		t.Errorf("should not suggest anything: %s", s)
+	}
+}
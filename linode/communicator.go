@@ -0,0 +1,45 @@
+package linode
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/packer/packer"
+)
+
+// runRemoteCommand runs command on the instance, streaming its output to the
+// UI, and fails if the command exits with a non-zero status.
+func runRemoteCommand(comm packer.Communicator, ui packer.Ui, command string) error {
+	cmd := &packer.RemoteCmd{Command: command}
+	if err := cmd.StartWithUi(comm, ui); err != nil {
+		return err
+	}
+	if cmd.ExitStatus != 0 {
+		return fmt.Errorf("command exited with status %d", cmd.ExitStatus)
+	}
+	return nil
+}
+
+// shellQuote quotes s as a single word for a POSIX shell.
+func shellQuote(s string) string {
+	return "'" + strings.Replace(s, "'", `'"'"'`, -1) + "'"
+}
+
+// remoteOutput runs command on the instance and returns its output.
+func remoteOutput(comm packer.Communicator, command string) (string, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := &packer.RemoteCmd{
+		Command: command,
+		Stdout:  &stdout,
+		Stderr:  &stderr,
+	}
+	if err := comm.Start(cmd); err != nil {
+		return "", err
+	}
+	cmd.Wait()
+	if cmd.ExitStatus != 0 {
+		return "", fmt.Errorf("command exited with status %d: %s", cmd.ExitStatus, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.String(), nil
+}
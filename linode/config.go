@@ -5,8 +5,10 @@ import (
 	"encoding/base64"
 	"errors"
 	"fmt"
+	"net/url"
 	"os"
 	"regexp"
+	"strings"
 	"time"
 
 	"github.com/hashicorp/packer/common"
@@ -22,22 +24,106 @@ type Config struct {
 	Comm                communicator.Config `mapstructure:",squash"`
 
 	PersonalAccessToken string `mapstructure:"linode_token"`
+	TokenFile           string `mapstructure:"linode_token_file"`
+	TokenCommand        string `mapstructure:"linode_token_command"`
+	LinodeCLIProfile    string `mapstructure:"linode_cli_profile"`
+	APIURL              string `mapstructure:"api_url"`
+	APIVersion          string `mapstructure:"api_version"`
+	APICAFile           string `mapstructure:"api_ca_file"`
 
-	Region       string   `mapstructure:"region"`
-	InstanceType string   `mapstructure:"instance_type"`
-	Label        string   `mapstructure:"instance_label"`
-	Tags         []string `mapstructure:"instance_tags"`
-	Image        string   `mapstructure:"image"`
-	SwapSize     int      `mapstructure:"swap_size"`
-	RootPass     string   `mapstructure:"root_pass"`
-	RootSSHKey   string   `mapstructure:"root_ssh_key"`
-	ImageLabel   string   `mapstructure:"image_label"`
-	Description  string   `mapstructure:"image_description"`
+	Region        string             `mapstructure:"region"`
+	Regions       []string           `mapstructure:"regions"`
+	InstanceType  string             `mapstructure:"instance_type"`
+	InstanceTypes []string           `mapstructure:"instance_types"`
+	Label         string             `mapstructure:"instance_label"`
+	Tags          []string           `mapstructure:"instance_tags"`
+	Image         string             `mapstructure:"image"`
+	ImageFilter   ImageFilterOptions `mapstructure:"image_filter"`
+	SwapSize      int                `mapstructure:"swap_size"`
+	RootPass      string             `mapstructure:"root_pass"`
+	RootSSHKey    string             `mapstructure:"root_ssh_key"`
+	ImageLabel    string             `mapstructure:"image_label"`
+	Description   string             `mapstructure:"image_description"`
+
+	SourceImageFromManifest string `mapstructure:"source_image_from_manifest"`
+	SourceManifestBuildName string `mapstructure:"source_manifest_build_name"`
+	SourceBuildTag          string `mapstructure:"source_build_tag"`
+	SourceInstanceID        int    `mapstructure:"source_instance_id"`
+	SourceInstanceClone     bool   `mapstructure:"source_instance_clone"`
+	BuildTag                string `mapstructure:"build_tag"`
+
+	ImageDescriptionMetadata string `mapstructure:"image_description_metadata"`
+
+	TargetInstanceTypes []string `mapstructure:"target_instance_types"`
+
+	Volumes []VolumeConfig `mapstructure:"volumes"`
+
+	Kernel      string             `mapstructure:"kernel"`
+	BootHelpers BootHelpersOptions `mapstructure:"boot_helpers"`
+	RunLevel    string             `mapstructure:"run_level"`
+
+	BootMode        string `mapstructure:"boot_mode"`
+	RescueMountPath string `mapstructure:"rescue_mount_path"`
+
+	RawExpectedBuildDuration string  `mapstructure:"expected_build_duration"`
+	MaxBuildCost             float64 `mapstructure:"max_build_cost"`
+	ImageQuotaCheck          string  `mapstructure:"image_quota_check"`
+	ImageCountLimit          int     `mapstructure:"image_count_limit"`
+	ImageSizeLimit           int     `mapstructure:"image_size_limit"`
+	ImageStorageLimit        int     `mapstructure:"image_storage_limit"`
 
 	RawStateTimeout string `mapstructure:"state_timeout"`
 
-	stateTimeout time.Duration
-	interCtx     interpolate.Context
+	APIValidation string `mapstructure:"api_validation"`
+
+	ShutdownCommand    string `mapstructure:"shutdown_command"`
+	RawShutdownTimeout string `mapstructure:"shutdown_timeout"`
+
+	SSHHostKeySeed         bool `mapstructure:"ssh_host_key_seed"`
+	DisablePasswordAuth    bool `mapstructure:"disable_password_auth"`
+	RemoveBuildCredentials bool `mapstructure:"remove_build_credentials"`
+
+	ImageSanitize       bool     `mapstructure:"image_sanitize"`
+	ImageSanitizeScript string   `mapstructure:"image_sanitize_script"`
+	ImageSanitizePaths  []string `mapstructure:"image_sanitize_paths"`
+
+	MetricsLogPath string `mapstructure:"metrics_log_path"`
+
+	TracingEndpoint    string `mapstructure:"tracing_endpoint"`
+	TracingFile        string `mapstructure:"tracing_file"`
+	TracingServiceName string `mapstructure:"tracing_service_name"`
+
+	ExportPath          string              `mapstructure:"export_path"`
+	ExportObjectStorage ObjectStorageConfig `mapstructure:"export_object_storage"`
+	ExportObjectKey     string              `mapstructure:"export_object_key"`
+	ExportChunkSize     int                 `mapstructure:"export_chunk_size"`
+	ExportRetries       int                 `mapstructure:"export_retries"`
+
+	ObjectStorage           ObjectStorageConfig `mapstructure:"object_storage"`
+	ObjectStorageURLs       map[string]string   `mapstructure:"object_storage_urls"`
+	ObjectStorageUploadURLs map[string]string   `mapstructure:"object_storage_upload_urls"`
+	RawObjectStorageURLTTL  string              `mapstructure:"object_storage_url_ttl"`
+	ObjectStorageEnvFile    string              `mapstructure:"object_storage_env_file"`
+	ObjectStorageLogPrefix  string              `mapstructure:"object_storage_log_prefix"`
+
+	KeepInstanceOnFailure bool   `mapstructure:"keep_instance_on_failure"`
+	RawDebugHoldDuration  string `mapstructure:"debug_hold_duration"`
+
+	ConsoleLogPath    string `mapstructure:"console_log_path"`
+	ConsoleLogLines   int    `mapstructure:"console_log_lines"`
+	ConsoleLogCommand string `mapstructure:"console_log_command"`
+	LishUsername      string `mapstructure:"lish_username"`
+	LishSSHKeyFile    string `mapstructure:"lish_ssh_key_file"`
+
+	target                string
+	rawImageLabel         string
+	rawDescription        string
+	stateTimeout          time.Duration
+	shutdownTimeout       time.Duration
+	debugHoldDuration     time.Duration
+	expectedBuildDuration time.Duration
+	objectStorageURLTTL   time.Duration
+	interCtx              interpolate.Context
 }
 
 func createRandomRootPassword() (string, error) {
@@ -59,6 +145,8 @@ func NewConfig(raws ...interface{}) (*Config, []string, error) {
 		InterpolateFilter: &interpolate.RenderFilter{
 			Exclude: []string{
 				"run_command",
+				"image_label",
+				"image_description",
 			},
 		},
 	}, raws...); err != nil {
@@ -69,17 +157,32 @@ func NewConfig(raws ...interface{}) (*Config, []string, error) {
 
 	// Defaults
 
-	if c.PersonalAccessToken == "" {
-		// Default to environment variable for linode_token, if it exists
-		c.PersonalAccessToken = os.Getenv("LINODE_TOKEN")
+	if err := c.resolveToken(); err != nil {
+		errs = packer.MultiErrorAppend(errs, err)
 	}
 
+	c.setAPIDefaults()
+
+	// image_label and image_description are rendered again once the build
+	// variables are known, the values rendered here only use those known
+	// upfront.
 	if c.ImageLabel == "" {
-		if def, err := interpolate.Render("packer-{{timestamp}}", nil); err == nil {
-			c.ImageLabel = def
-		} else {
-			errs = packer.MultiErrorAppend(errs, fmt.Errorf("Unable to render image name: %s", err))
-		}
+		c.ImageLabel = "packer-{{timestamp}}"
+	}
+	c.rawImageLabel, c.rawDescription = c.ImageLabel, c.Description
+
+	info := newBuildInfo(c, nil)
+	if label, err := renderImageLabel(c, info); err == nil {
+		c.ImageLabel = label
+	} else {
+		errs = packer.MultiErrorAppend(errs, fmt.Errorf("Unable to render image name: %s", err))
+	}
+	ctx := c.ctx
+	ctx.Data = info
+	if description, err := interpolate.Render(c.rawDescription, &ctx); err == nil {
+		c.Description = description
+	} else {
+		errs = packer.MultiErrorAppend(errs, fmt.Errorf("Unable to render image description: %s", err))
 	}
 
 	if c.Label == "" {
@@ -109,11 +212,90 @@ func NewConfig(raws ...interface{}) (*Config, []string, error) {
 		}
 	}
 
+	if c.RawShutdownTimeout == "" {
+		c.shutdownTimeout = 5 * time.Minute
+	} else {
+		if shutdownTimeout, err := time.ParseDuration(c.RawShutdownTimeout); err == nil {
+			c.shutdownTimeout = shutdownTimeout
+		} else {
+			errs = packer.MultiErrorAppend(errs, fmt.Errorf("Unable to parse shutdown timeout: %s", err))
+		}
+	}
+
+	if c.RawDebugHoldDuration != "" {
+		if debugHoldDuration, err := time.ParseDuration(c.RawDebugHoldDuration); err == nil {
+			c.debugHoldDuration = debugHoldDuration
+		} else {
+			errs = packer.MultiErrorAppend(errs, fmt.Errorf("Unable to parse debug hold duration: %s", err))
+		}
+	}
+
+	if c.RawObjectStorageURLTTL == "" {
+		c.objectStorageURLTTL = time.Hour
+	} else {
+		if objectStorageURLTTL, err := time.ParseDuration(c.RawObjectStorageURLTTL); err == nil {
+			c.objectStorageURLTTL = objectStorageURLTTL
+		} else {
+			errs = packer.MultiErrorAppend(errs, fmt.Errorf("Unable to parse object storage URL TTL: %s", err))
+		}
+	}
+
+	if c.ObjectStorageEnvFile == "" {
+		c.ObjectStorageEnvFile = "/tmp/packer-object-storage.env"
+	}
+
+	if c.RawExpectedBuildDuration == "" {
+		c.expectedBuildDuration = time.Hour
+	} else {
+		if expectedBuildDuration, err := time.ParseDuration(c.RawExpectedBuildDuration); err == nil {
+			c.expectedBuildDuration = expectedBuildDuration
+		} else {
+			errs = packer.MultiErrorAppend(errs, fmt.Errorf("Unable to parse expected build duration: %s", err))
+		}
+	}
+
+	if c.ImageQuotaCheck == "" {
+		c.ImageQuotaCheck = "warn"
+	}
+
+	if c.APIValidation == "" {
+		c.APIValidation = "build"
+	}
+
+	if c.BootMode == "" {
+		c.BootMode = "normal"
+	}
+
+	if c.RescueMountPath == "" {
+		c.RescueMountPath = "/mnt/packer"
+	}
+
+	if c.TracingServiceName == "" {
+		c.TracingServiceName = "packer"
+	}
+
+	if c.ConsoleLogLines == 0 {
+		c.ConsoleLogLines = 20
+	}
+
+	if c.ExportChunkSize == 0 {
+		c.ExportChunkSize = 256
+	}
+
+	if c.ExportRetries == 0 {
+		c.ExportRetries = 3
+	}
+
 	if es := c.Comm.Prepare(&c.ctx); len(es) > 0 {
 		errs = packer.MultiErrorAppend(errs, es...)
 	}
 
-	c.Comm.SSHPassword = c.RootPass
+	// The API requires a root password even when only key authentication is
+	// used, so one is generated regardless and just not handed to the
+	// communicator. An existing instance keeps its own password.
+	if !c.DisablePasswordAuth && c.SourceInstanceID == 0 {
+		c.Comm.SSHPassword = c.RootPass
+	}
 
 	if c.PersonalAccessToken == "" {
 		// Required configurations that will display errors if not set
@@ -121,19 +303,253 @@ func NewConfig(raws ...interface{}) (*Config, []string, error) {
 			errs, errors.New("linode_token is required"))
 	}
 
-	if c.Region == "" {
+	if u, err := url.Parse(c.APIURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		errs = packer.MultiErrorAppend(
+			errs, fmt.Errorf("api_url must be an http or https URL: %s", c.APIURL))
+	}
+
+	switch c.APIVersion {
+	case "v4", "v4beta":
+	default:
+		errs = packer.MultiErrorAppend(
+			errs, fmt.Errorf("api_version must be v4 or v4beta: %s", c.APIVersion))
+	}
+
+	if c.APICAFile != "" {
+		if _, err := loadCAFile(c.APICAFile); err != nil {
+			errs = packer.MultiErrorAppend(
+				errs, fmt.Errorf("api_ca_file is not valid: %s", err))
+		}
+	}
+
+	if c.Region == "" && len(c.Regions) == 0 && c.SourceInstanceID == 0 {
+		errs = packer.MultiErrorAppend(
+			errs, errors.New("region or regions is required"))
+	} else if c.Region != "" && len(c.Regions) > 0 {
+		errs = packer.MultiErrorAppend(
+			errs, errors.New("only one of region or regions can be set"))
+	}
+
+	if c.InstanceType == "" && len(c.InstanceTypes) == 0 && c.SourceInstanceID == 0 {
+		errs = packer.MultiErrorAppend(
+			errs, errors.New("instance_type or instance_types is required"))
+	} else if c.InstanceType != "" && len(c.InstanceTypes) > 0 {
+		errs = packer.MultiErrorAppend(
+			errs, errors.New("only one of instance_type or instance_types can be set"))
+	}
+
+	for _, target := range append(append([]string{}, c.Regions...), c.InstanceTypes...) {
+		if target == "" {
+			errs = packer.MultiErrorAppend(
+				errs, errors.New("regions and instance_types can't contain empty values"))
+			break
+		}
+	}
+
+	// Concurrent builds would prompt at the same time on the same terminal.
+	if (c.PackerDebug || c.PackerOnError == "ask") && len(c.targets()) > 1 {
+		errs = packer.MultiErrorAppend(
+			errs, errors.New("-debug and -on-error=ask can't be used when building several images"))
+	}
+
+	sources := 0
+	for _, set := range []bool{
+		c.Image != "",
+		!c.ImageFilter.Empty(),
+		c.SourceImageFromManifest != "",
+		c.SourceBuildTag != "",
+		c.SourceInstanceID != 0,
+	} {
+		if set {
+			sources++
+		}
+	}
+	if sources == 0 {
+		errs = packer.MultiErrorAppend(
+			errs, errors.New("image, image_filter, source_image_from_manifest, source_build_tag or source_instance_id is required"))
+	} else if sources > 1 {
+		errs = packer.MultiErrorAppend(
+			errs, errors.New("only one of image, image_filter, source_image_from_manifest, source_build_tag or source_instance_id can be set"))
+	}
+
+	if c.SourceInstanceID != 0 {
+		errs = packer.MultiErrorAppend(errs, c.prepareSourceInstance()...)
+	} else if c.SourceInstanceClone {
 		errs = packer.MultiErrorAppend(
-			errs, errors.New("region is required"))
+			errs, errors.New("source_instance_clone requires source_instance_id"))
 	}
 
-	if c.InstanceType == "" {
+	if _, err := regexp.Compile(c.ImageFilter.Label); err != nil {
 		errs = packer.MultiErrorAppend(
-			errs, errors.New("instance_type is required"))
+			errs, fmt.Errorf("image_filter label is not a valid regular expression: %s", err))
 	}
 
-	if c.Image == "" {
+	switch c.ImageDescriptionMetadata {
+	case "", "json", "yaml":
+	default:
 		errs = packer.MultiErrorAppend(
-			errs, errors.New("image is required"))
+			errs, fmt.Errorf("image_description_metadata must be json or yaml: %s", c.ImageDescriptionMetadata))
+	}
+
+	switch c.RunLevel {
+	case "", "default", "single", "binbash":
+	default:
+		errs = packer.MultiErrorAppend(
+			errs, fmt.Errorf("run_level must be one of default, single or binbash: %s", c.RunLevel))
+	}
+
+	switch c.BootMode {
+	case "normal":
+	case "rescue":
+		if c.LishSSHKeyFile == "" {
+			errs = packer.MultiErrorAppend(
+				errs, errors.New("lish_ssh_key_file is required when boot_mode is rescue"))
+		}
+		if c.Comm.Type != "ssh" {
+			errs = packer.MultiErrorAppend(
+				errs, errors.New("boot_mode rescue requires the ssh communicator"))
+		}
+		if c.SSHHostKeySeed {
+			errs = packer.MultiErrorAppend(
+				errs, errors.New("ssh_host_key_seed can't be used with boot_mode rescue"))
+		}
+		if c.customBoot() {
+			errs = packer.MultiErrorAppend(
+				errs, errors.New("kernel, boot_helpers and run_level can't be used with boot_mode rescue"))
+		}
+		if !strings.HasPrefix(c.RescueMountPath, "/") || c.RescueMountPath == "/" {
+			errs = packer.MultiErrorAppend(
+				errs, fmt.Errorf("rescue_mount_path must be an absolute path: %s", c.RescueMountPath))
+		}
+	default:
+		errs = packer.MultiErrorAppend(
+			errs, fmt.Errorf("boot_mode must be normal or rescue: %s", c.BootMode))
+	}
+
+	if len(c.Volumes) > maxVolumes {
+		errs = packer.MultiErrorAppend(
+			errs, fmt.Errorf("at most %d volumes can be attached", maxVolumes))
+	}
+	for i := range c.Volumes {
+		errs = packer.MultiErrorAppend(errs, c.Volumes[i].prepare(i)...)
+		if c.Volumes[i].ID != 0 && len(c.targets()) > 1 {
+			errs = packer.MultiErrorAppend(
+				errs, fmt.Errorf("volumes[%d]: an existing volume can't be attached when building several images", i))
+		}
+	}
+	if len(c.Volumes) > 0 && c.BootMode == "rescue" {
+		errs = packer.MultiErrorAppend(
+			errs, errors.New("volumes can't be used with boot_mode rescue"))
+	}
+
+	switch c.ImageQuotaCheck {
+	case "warn", "fail", "off":
+	default:
+		errs = packer.MultiErrorAppend(
+			errs, fmt.Errorf("image_quota_check must be one of warn, fail or off: %s", c.ImageQuotaCheck))
+	}
+
+	if c.ImageCountLimit < 0 || c.ImageSizeLimit < 0 || c.ImageStorageLimit < 0 {
+		errs = packer.MultiErrorAppend(
+			errs, errors.New("image_count_limit, image_size_limit and image_storage_limit can't be negative"))
+	}
+
+	if c.MaxBuildCost < 0 {
+		errs = packer.MultiErrorAppend(
+			errs, fmt.Errorf("max_build_cost can't be negative: %v", c.MaxBuildCost))
+	}
+
+	switch c.APIValidation {
+	case "build", "prepare", "off":
+	default:
+		errs = packer.MultiErrorAppend(
+			errs, fmt.Errorf("api_validation must be one of build, prepare or off: %s", c.APIValidation))
+	}
+
+	if c.TracingEndpoint != "" {
+		if u, err := url.Parse(c.TracingEndpoint); err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			errs = packer.MultiErrorAppend(
+				errs, fmt.Errorf("tracing_endpoint must be an http or https URL: %s", c.TracingEndpoint))
+		}
+	}
+
+	if c.RawDebugHoldDuration != "" && !c.KeepInstanceOnFailure {
+		errs = packer.MultiErrorAppend(
+			errs, errors.New("debug_hold_duration requires keep_instance_on_failure"))
+	}
+
+	if c.exportImage() {
+		if c.LishSSHKeyFile == "" {
+			errs = packer.MultiErrorAppend(
+				errs, errors.New("lish_ssh_key_file is required to export the image"))
+		}
+		if c.Comm.Type != "ssh" {
+			errs = packer.MultiErrorAppend(
+				errs, errors.New("exporting the image requires the ssh communicator"))
+		}
+		if c.ExportChunkSize < 0 {
+			errs = packer.MultiErrorAppend(
+				errs, fmt.Errorf("export_chunk_size must be positive: %d", c.ExportChunkSize))
+		}
+		if c.ExportRetries < 0 {
+			errs = packer.MultiErrorAppend(
+				errs, fmt.Errorf("export_retries can't be negative: %d", c.ExportRetries))
+		}
+	}
+
+	if !c.ObjectStorage.Empty() {
+		errs = packer.MultiErrorAppend(errs, c.ObjectStorage.prepare("object_storage")...)
+	} else if len(c.ObjectStorageURLs) > 0 || len(c.ObjectStorageUploadURLs) > 0 {
+		errs = packer.MultiErrorAppend(
+			errs, errors.New("object_storage_urls and object_storage_upload_urls require object_storage"))
+	}
+
+	envNameRe := regexp.MustCompile("^[A-Za-z_][A-Za-z0-9_]*$")
+	for _, urls := range []map[string]string{c.ObjectStorageURLs, c.ObjectStorageUploadURLs} {
+		for name, key := range urls {
+			if !envNameRe.MatchString(name) {
+				errs = packer.MultiErrorAppend(
+					errs, fmt.Errorf("invalid environment variable name for object %s: %s", key, name))
+			}
+		}
+	}
+	for name := range c.ObjectStorageUploadURLs {
+		if _, ok := c.ObjectStorageURLs[name]; ok {
+			errs = packer.MultiErrorAppend(
+				errs, fmt.Errorf("%s is in both object_storage_urls and object_storage_upload_urls", name))
+		}
+	}
+
+	// Presigned URLs can't be valid for more than a week.
+	if c.objectStorageURLTTL <= 0 || c.objectStorageURLTTL > 7*24*time.Hour {
+		errs = packer.MultiErrorAppend(
+			errs, fmt.Errorf("object_storage_url_ttl must be between 0 and 168h: %s", c.objectStorageURLTTL))
+	}
+
+	if !c.ExportObjectStorage.Empty() {
+		errs = packer.MultiErrorAppend(errs, c.ExportObjectStorage.prepare("export_object_storage")...)
+	} else if c.ExportObjectKey != "" {
+		errs = packer.MultiErrorAppend(
+			errs, errors.New("export_object_key requires export_object_storage"))
+	}
+
+	if c.ConsoleLogPath != "" && c.ConsoleLogCommand == "" && c.LishSSHKeyFile == "" {
+		errs = packer.MultiErrorAppend(
+			errs, errors.New("lish_ssh_key_file or console_log_command is required when console_log_path is set"))
+	}
+
+	if c.ImageSanitizeScript != "" {
+		if _, err := os.Stat(c.ImageSanitizeScript); err != nil {
+			errs = packer.MultiErrorAppend(
+				errs, fmt.Errorf("image_sanitize_script is not valid: %s", err))
+		}
+	}
+
+	for _, p := range c.ImageSanitizePaths {
+		if !strings.HasPrefix(p, "/") || strings.Contains(p, "'") {
+			errs = packer.MultiErrorAppend(
+				errs, fmt.Errorf("invalid image_sanitize_paths entry: %s", p))
+		}
 	}
 
 	if c.Tags == nil {
@@ -147,10 +563,102 @@ func NewConfig(raws ...interface{}) (*Config, []string, error) {
 		}
 	}
 
+	for _, t := range []string{c.BuildTag, c.SourceBuildTag} {
+		if t != "" && !tagRe.MatchString(t) {
+			errs = packer.MultiErrorAppend(errs, fmt.Errorf("invalid build tag: %s", t))
+		}
+	}
+
 	if errs != nil && len(errs.Errors) > 0 {
 		return nil, nil, errs
 	}
 
+	var warnings []string
+	if !c.timeSteps() && (c.MetricsLogPath != "" || c.TracingEndpoint != "" || c.TracingFile != "") {
+		warnings = append(warnings,
+			"steps are not timed or traced with -debug or -on-error=ask, only API calls are recorded")
+	}
+
 	packer.LogSecretFilter.Set(c.PersonalAccessToken)
-	return c, nil, nil
+	return c, warnings, nil
+}
+
+// setAPIDefaults sets the API endpoint options left empty from the
+// environment, or to their default values.
+func (c *Config) setAPIDefaults() {
+	if c.APIURL == "" {
+		c.APIURL = os.Getenv("LINODE_URL")
+	}
+	if c.APIURL == "" {
+		c.APIURL = defaultAPIURL
+	}
+
+	if c.APIVersion == "" {
+		c.APIVersion = os.Getenv("LINODE_API_VERSION")
+	}
+	if c.APIVersion == "" {
+		c.APIVersion = defaultAPIVersion
+	}
+
+	if c.APICAFile == "" {
+		c.APICAFile = os.Getenv("LINODE_CA")
+	}
+}
+
+// resolveImage reports whether the source image needs to be looked up, either
+// to find out which image to use or because it may be the parent of the image.
+// Public images are validated against the catalog and need no lookup.
+func (c *Config) resolveImage() bool {
+	return !c.ImageFilter.Empty() || c.SourceImageFromManifest != "" || c.SourceBuildTag != "" ||
+		strings.HasPrefix(c.Image, "private/")
+}
+
+// checkImageQuota reports whether the image limits of the account are checked.
+// The API doesn't expose them, so they are only known if they are configured.
+func (c *Config) checkImageQuota() bool {
+	return c.ImageQuotaCheck != "off" && (c.ImageCountLimit > 0 || c.ImageSizeLimit > 0 || c.ImageStorageLimit > 0)
+}
+
+// exportImage reports whether the image is exported once captured.
+func (c *Config) exportImage() bool {
+	return c.ExportPath != "" || !c.ExportObjectStorage.Empty()
+}
+
+// objectStorageLogKey returns the key the file name of the build logs is
+// uploaded to.
+func (c *Config) objectStorageLogKey(name string) string {
+	prefix := c.ObjectStorageLogPrefix
+	if prefix == "" {
+		prefix = "packer/" + c.Label + "/"
+	} else if c.target != "" {
+		prefix += c.target + "/"
+	}
+	return prefix + name
+}
+
+// prepareSourceInstance validates the options that can be used when an
+// existing instance is captured.
+func (c *Config) prepareSourceInstance() []error {
+	var errs []error
+	if len(c.Regions) > 0 || len(c.InstanceTypes) > 0 {
+		errs = append(errs, errors.New("regions and instance_types can't be used with source_instance_id"))
+	}
+	if c.SSHHostKeySeed || c.customBoot() || c.BootMode != "normal" || len(c.Volumes) > 0 {
+		errs = append(errs, errors.New("ssh_host_key_seed, kernel, boot_helpers, run_level, boot_mode and volumes can't be used with source_instance_id"))
+	}
+	if c.Comm.Type == "ssh" && c.Comm.SSHPrivateKeyFile == "" && c.Comm.SSHPassword == "" && !c.Comm.SSHAgentAuth {
+		errs = append(errs, errors.New("ssh_private_key_file, ssh_password or ssh_agent_auth is required to connect to source_instance_id"))
+	}
+
+	// Anything that changes the instance other than provisioning, or boots
+	// it into rescue mode, is only done on a clone.
+	if !c.SourceInstanceClone {
+		if c.Region != "" || c.InstanceType != "" {
+			errs = append(errs, errors.New("region and instance_type can only be set with source_instance_clone"))
+		}
+		if c.RemoveBuildCredentials || c.ImageSanitize || c.exportImage() {
+			errs = append(errs, errors.New("remove_build_credentials, image_sanitize and image exports require source_instance_clone"))
+		}
+	}
+	return errs
 }
@@ -0,0 +1,187 @@
+package linode
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/linode/linodego"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// consoleSource fetches the serial console output of a Linode instance.
+type consoleSource interface {
+	ConsoleLog(ctx context.Context, instance *linodego.Instance) ([]byte, error)
+}
+
+// lishGateways maps regions to the Lish SSH gateway serving them.
+var lishGateways = map[string]string{
+	"us-east":      "lish-newark.linode.com",
+	"us-central":   "lish-dallas.linode.com",
+	"us-west":      "lish-fremont.linode.com",
+	"us-southeast": "lish-atlanta.linode.com",
+	"ca-central":   "lish-toronto1.linode.com",
+	"eu-west":      "lish-london.linode.com",
+	"eu-central":   "lish-frankfurt.linode.com",
+	"ap-south":     "lish-singapore.linode.com",
+	"ap-northeast": "lish-tokyo2.linode.com",
+	"ap-west":      "lish-mumbai1.linode.com",
+	"ap-southeast": "lish-sydney.linode.com",
+}
+
+// lishConsole reads the console scrollback through the Lish gateway using
+// Lish's logview command.
+type lishConsole struct {
+	client   linodego.Client
+	username string
+	keyFile  string
+}
+
+func (l *lishConsole) ConsoleLog(ctx context.Context, instance *linodego.Instance) ([]byte, error) {
+	conn, err := dialLish(ctx, l.client, l.username, l.keyFile, instance.Region)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	session, err := conn.NewSession()
+	if err != nil {
+		return nil, err
+	}
+	defer session.Close()
+
+	return session.Output(fmt.Sprintf("%s logview", instance.Label))
+}
+
+// dialLish connects to the Lish gateway serving region. The connection is
+// closed when ctx is done, as that is the only way to interrupt a running
+// session.
+func dialLish(ctx context.Context, client linodego.Client, username, keyFile, region string) (*ssh.Client, error) {
+	gateway, ok := lishGateways[region]
+	if !ok {
+		return nil, fmt.Errorf("no Lish gateway known for region %s", region)
+	}
+
+	if username == "" {
+		profile, err := client.GetProfile(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("unable to look up Lish username: %s", err)
+		}
+		username = profile.Username
+	}
+
+	sshConfig, err := lishClientConfig(username, keyFile, gateway)
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := ssh.Dial("tcp", gateway+":22", sshConfig)
+	if err != nil {
+		return nil, fmt.Errorf("unable to connect to %s: %s", gateway, err)
+	}
+
+	closed := make(chan struct{})
+	go func() {
+		conn.Wait()
+		close(closed)
+	}()
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.Close()
+		case <-closed:
+		}
+	}()
+
+	return conn, nil
+}
+
+// lishClientConfig returns the configuration to log in to gateway with. The
+// host key of the gateway is verified against the known_hosts file of the
+// user, as the connection carries the console of the instance.
+func lishClientConfig(username, keyFile, gateway string) (*ssh.ClientConfig, error) {
+	keyBytes, err := ioutil.ReadFile(keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read Lish key: %s", err)
+	}
+	signer, err := ssh.ParsePrivateKey(keyBytes)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse Lish key: %s", err)
+	}
+
+	knownHostsFile := filepath.Join(os.Getenv("HOME"), ".ssh", "known_hosts")
+	hostKeyError := func(err error) error {
+		return fmt.Errorf("unable to verify the host key of the Lish gateway, "+
+			"connect to %s with ssh once to add it to %s: %s", gateway, knownHostsFile, err)
+	}
+	knownHosts, err := knownhosts.New(knownHostsFile)
+	if err != nil {
+		return nil, hostKeyError(err)
+	}
+	hostKeyCallback := func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		if err := knownHosts(hostname, remote, key); err != nil {
+			return hostKeyError(err)
+		}
+		return nil
+	}
+
+	return &ssh.ClientConfig{
+		User:            username,
+		Auth:            []ssh.AuthMethod{ssh.PublicKeys(signer)},
+		HostKeyCallback: hostKeyCallback,
+	}, nil
+}
+
+// commandConsole runs a local command and treats its stdout as the console
+// log. The instance is described to the command through environment
+// variables so that it can be stubbed out or pointed at another log source.
+type commandConsole struct {
+	command string
+}
+
+func (c *commandConsole) ConsoleLog(ctx context.Context, instance *linodego.Instance) ([]byte, error) {
+	shell, flag := localShell()
+
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, shell, flag, c.command)
+	cmd.Stderr = &stderr
+	cmd.Env = append(os.Environ(),
+		"LINODE_ID="+strconv.Itoa(instance.ID),
+		"LINODE_LABEL="+instance.Label,
+		"LINODE_REGION="+instance.Region,
+	)
+
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("console log command failed: %s: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return out, nil
+}
+
+func newConsoleSource(c *Config, client linodego.Client) consoleSource {
+	if c.ConsoleLogCommand != "" {
+		return &commandConsole{command: c.ConsoleLogCommand}
+	}
+	return &lishConsole{
+		client:   client,
+		username: c.LishUsername,
+		keyFile:  c.LishSSHKeyFile,
+	}
+}
+
+// tailLines returns at most the last n lines of out.
+func tailLines(out []byte, n int) []string {
+	lines := strings.Split(strings.TrimRight(string(out), "\r\n"), "\n")
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return lines
+}
@@ -0,0 +1,40 @@
+package linode
+
+import (
+	"context"
+	"reflect"
+	"runtime"
+	"testing"
+
+	"github.com/linode/linodego"
+)
+
+func TestTailLines(t *testing.T) {
+	out := []byte("one\ntwo\nthree\n")
+
+	if lines := tailLines(out, 2); !reflect.DeepEqual(lines, []string{"two", "three"}) {
+		t.Fatalf("bad: %#v", lines)
+	}
+	if lines := tailLines(out, 10); len(lines) != 3 {
+		t.Fatalf("bad: %#v", lines)
+	}
+}
+
+func TestCommandConsole(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a POSIX shell")
+	}
+
+	source := &commandConsole{command: `echo "$LINODE_ID $LINODE_LABEL $LINODE_REGION"`}
+	instance := &linodego.Instance{ID: 42, Label: "packer-foobar", Region: "us-east"}
+
+	out, err := source.ConsoleLog(context.Background(), instance)
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+
+	expected := "42 packer-foobar us-east\n"
+	if string(out) != expected {
+		t.Fatalf("found %q, expected %q", out, expected)
+	}
+}
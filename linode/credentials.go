@@ -0,0 +1,161 @@
+package linode
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// tokenCommandTimeout bounds how long linode_token_command may run, so that a
+// command waiting for input doesn't hang the build.
+var tokenCommandTimeout = 30 * time.Second
+
+// resolveToken sets PersonalAccessToken from the configured credential
+// source: linode_token, linode_token_file, linode_token_command, the
+// LINODE_TOKEN environment variable, or the linode-cli configuration, in that
+// order.
+func (c *Config) resolveToken() error {
+	sources := 0
+	for _, set := range []bool{
+		c.PersonalAccessToken != "",
+		c.TokenFile != "",
+		c.TokenCommand != "",
+		c.LinodeCLIProfile != "",
+	} {
+		if set {
+			sources++
+		}
+	}
+	if sources > 1 {
+		return errors.New("only one of linode_token, linode_token_file, linode_token_command or linode_cli_profile can be set")
+	}
+
+	switch {
+	case c.PersonalAccessToken != "":
+		return nil
+	case c.TokenFile != "":
+		data, err := ioutil.ReadFile(c.TokenFile)
+		if err != nil {
+			return fmt.Errorf("unable to read linode_token_file: %s", err)
+		}
+		c.PersonalAccessToken = strings.TrimSpace(string(data))
+	case c.TokenCommand != "":
+		token, err := tokenFromCommand(c.TokenCommand)
+		if err != nil {
+			return fmt.Errorf("linode_token_command failed: %s", err)
+		}
+		c.PersonalAccessToken = token
+	case c.LinodeCLIProfile == "" && os.Getenv("LINODE_TOKEN") != "":
+		c.PersonalAccessToken = os.Getenv("LINODE_TOKEN")
+	default:
+		token, err := tokenFromLinodeCLI(linodeCLIConfigPath(), c.LinodeCLIProfile)
+		if err != nil && c.LinodeCLIProfile != "" {
+			return fmt.Errorf("unable to read the linode-cli configuration: %s", err)
+		}
+		c.PersonalAccessToken = token
+	}
+	return nil
+}
+
+// tokenFromCommand runs command and returns its output. The command runs once,
+// when the template is prepared, so its output is used for the whole build.
+func tokenFromCommand(command string) (string, error) {
+	shell, flag := localShell()
+
+	ctx, cancel := context.WithTimeout(context.Background(), tokenCommandTimeout)
+	defer cancel()
+
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, shell, flag, command)
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if ctx.Err() == context.DeadlineExceeded {
+		return "", fmt.Errorf("timed out after %s", tokenCommandTimeout)
+	}
+	if err != nil {
+		return "", fmt.Errorf("%s: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	token := strings.TrimSpace(string(out))
+	if token == "" {
+		return "", errors.New("no token was printed")
+	}
+	return token, nil
+}
+
+// linodeCLIConfigPath returns where linode-cli stores its configuration.
+func linodeCLIConfigPath() string {
+	dir := os.Getenv("XDG_CONFIG_HOME")
+	if dir == "" {
+		dir = filepath.Join(os.Getenv("HOME"), ".config")
+	}
+	return filepath.Join(dir, "linode-cli")
+}
+
+// tokenFromLinodeCLI returns the token of profile in the linode-cli
+// configuration at path. The default user of the configuration is used if
+// profile is empty.
+func tokenFromLinodeCLI(path, profile string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	sections := make(map[string]map[string]string)
+	section := ""
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";"):
+		case strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]"):
+			section = strings.TrimSpace(line[1 : len(line)-1])
+		default:
+			kv := strings.SplitN(line, "=", 2)
+			if len(kv) != 2 {
+				continue
+			}
+			if sections[section] == nil {
+				sections[section] = make(map[string]string)
+			}
+			sections[section][strings.TrimSpace(kv[0])] = strings.TrimSpace(kv[1])
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", err
+	}
+
+	if profile == "" {
+		profile = sections["DEFAULT"]["default-user"]
+		if profile == "" {
+			return "", fmt.Errorf("no default-user in %s", path)
+		}
+	}
+	values, ok := sections[profile]
+	if !ok {
+		return "", fmt.Errorf("no profile %s in %s", profile, path)
+	}
+	if values["token"] == "" {
+		return "", fmt.Errorf("no token for profile %s in %s", profile, path)
+	}
+	return values["token"], nil
+}
+
+// localShell returns the shell used to run local commands, and the flag
+// passing it a command.
+func localShell() (string, string) {
+	if runtime.GOOS == "windows" {
+		return "cmd", "/C"
+	}
+	return "/bin/sh", "-c"
+}
@@ -0,0 +1,97 @@
+package linode
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testLinodeCLIConfig = `[DEFAULT]
+default-user = alice
+
+[alice]
+token = alice-token
+region = us-east
+
+[bob]
+token = bob-token
+`
+
+func TestTokenFromLinodeCLI(t *testing.T) {
+	dir, err := ioutil.TempDir("", "packer")
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "linode-cli")
+	if err := ioutil.WriteFile(path, []byte(testLinodeCLIConfig), 0600); err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+
+	for profile, expected := range map[string]string{"": "alice-token", "bob": "bob-token"} {
+		token, err := tokenFromLinodeCLI(path, profile)
+		if err != nil {
+			t.Fatalf("should not have error: %s", err)
+		}
+		if token != expected {
+			t.Errorf("found %s, expected %s", token, expected)
+		}
+	}
+
+	if _, err := tokenFromLinodeCLI(path, "carol"); err == nil {
+		t.Fatal("should have error")
+	}
+}
+
+func TestResolveToken(t *testing.T) {
+	dir, err := ioutil.TempDir("", "packer")
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "token")
+	if err := ioutil.WriteFile(path, []byte("file-token\n"), 0600); err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+
+	c := &Config{TokenFile: path}
+	if err := c.resolveToken(); err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+	if c.PersonalAccessToken != "file-token" {
+		t.Errorf("bad: %s", c.PersonalAccessToken)
+	}
+
+	if runtime.GOOS != "windows" {
+		c = &Config{TokenCommand: "echo command-token"}
+		if err := c.resolveToken(); err != nil {
+			t.Fatalf("should not have error: %s", err)
+		}
+		if c.PersonalAccessToken != "command-token" {
+			t.Errorf("bad: %s", c.PersonalAccessToken)
+		}
+
+		c = &Config{TokenCommand: "exit 1"}
+		if err := c.resolveToken(); err == nil {
+			t.Fatal("should have error")
+		}
+
+		defer func(timeout time.Duration) { tokenCommandTimeout = timeout }(tokenCommandTimeout)
+		tokenCommandTimeout = 100 * time.Millisecond
+		c = &Config{TokenCommand: "exec sleep 5"}
+		if err := c.resolveToken(); err == nil || !strings.Contains(err.Error(), "timed out") {
+			t.Fatalf("slow commands should time out: %v", err)
+		}
+	}
+
+	c = &Config{PersonalAccessToken: "token", TokenFile: path}
+	if err := c.resolveToken(); err == nil {
+		t.Fatal("should have error")
+	}
+}
@@ -0,0 +1,216 @@
+package linode
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+	"github.com/hashicorp/packer/packer"
+)
+
+// exportDisk copies the disk of the instance booted in the rescue environment
+// to f, compressed with gzip, chunkSize MiB at a time. Each chunk is a gzip
+// member of its own, checked against the SHA-256 of the disk contents computed
+// on the instance. A chunk that fails to transfer or doesn't match is
+// transferred again, up to retries times, resuming after the last chunk
+// written.
+func exportDisk(ctx context.Context, comm packer.Communicator, ui packer.Ui, f *os.File, sizeMiB, chunkSize, retries int) error {
+	for offset := 0; offset < sizeMiB; offset += chunkSize {
+		count := chunkSize
+		if offset+count > sizeMiB {
+			count = sizeMiB - offset
+		}
+
+		for attempt := 0; ; attempt++ {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			err := exportChunk(comm, f, offset, count)
+			if err == nil {
+				break
+			}
+			if attempt >= retries {
+				return fmt.Errorf("unable to export %d MiB at %d MiB: %s", count, offset, err)
+			}
+			ui.Message(fmt.Sprintf("Retrying the export at %d MiB: %s", offset, err))
+		}
+		ui.Message(fmt.Sprintf("Exported %d of %d MiB", offset+count, sizeMiB))
+	}
+	return nil
+}
+
+// exportChunk appends count MiB of the disk, starting at offset MiB, to f. f
+// is truncated back to where it was if it fails.
+func exportChunk(comm packer.Communicator, f *os.File, offset, count int) (err error) {
+	start, err := f.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			f.Truncate(start)
+			f.Seek(start, io.SeekStart)
+		}
+	}()
+
+	dd := fmt.Sprintf("dd if=%s bs=1M skip=%d count=%d status=none", rescueDiskDevice, offset, count)
+	out, err := remoteOutput(comm, dd+" | sha256sum")
+	if err != nil {
+		return err
+	}
+	fields := strings.Fields(out)
+	if len(fields) == 0 {
+		return errors.New("no checksum computed")
+	}
+	expected := fields[0]
+
+	var stderr bytes.Buffer
+	cmd := &packer.RemoteCmd{
+		Command: dd + " | gzip -c",
+		Stdout:  f,
+		Stderr:  &stderr,
+	}
+	if err := comm.Start(cmd); err != nil {
+		return err
+	}
+	cmd.Wait()
+	if cmd.ExitStatus != 0 {
+		return fmt.Errorf("command exited with status %d: %s", cmd.ExitStatus, strings.TrimSpace(stderr.String()))
+	}
+
+	end, err := f.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+	sum, err := chunkChecksum(f, start, end)
+	if err != nil {
+		return err
+	}
+	if sum != expected {
+		return fmt.Errorf("checksum mismatch: expected %s, got %s", expected, sum)
+	}
+	return nil
+}
+
+// chunkChecksum returns the SHA-256 of the decompressed contents of the gzip
+// data of r between start and end.
+func chunkChecksum(r io.ReaderAt, start, end int64) (string, error) {
+	gz, err := gzip.NewReader(io.NewSectionReader(r, start, end-start))
+	if err != nil {
+		return "", err
+	}
+	defer gz.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, gz); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// fileChecksum returns the SHA-256 of the file at path.
+func fileChecksum(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// writeChecksumFile writes sum to path.sha256, in the format of sha256sum.
+func writeChecksumFile(path, sum string) (string, error) {
+	checksumPath := path + ".sha256"
+	line := fmt.Sprintf("%s  %s\n", sum, filepath.Base(path))
+	return checksumPath, ioutil.WriteFile(checksumPath, []byte(line), 0644)
+}
+
+// uploadExport uploads the file at path to key of the bucket of o, along with
+// its checksum, unless it is already there. The upload is retried up to
+// retries times, and checked against the size and checksum of the file.
+func uploadExport(ctx context.Context, o *ObjectStorageConfig, key, path, sum string, retries int) error {
+	sess, err := o.session()
+	if err != nil {
+		return err
+	}
+	svc := s3.New(sess)
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if uploaded(ctx, svc, o.Bucket, key, info.Size(), sum) == nil {
+		return nil
+	}
+
+	uploader := s3manager.NewUploader(sess, func(u *s3manager.Uploader) {
+		u.PartSize = 64 * 1024 * 1024
+	})
+	for attempt := 0; ; attempt++ {
+		err = uploadFile(ctx, uploader, o.Bucket, key, path, sum)
+		if err == nil {
+			err = uploaded(ctx, svc, o.Bucket, key, info.Size(), sum)
+		}
+		if err == nil || attempt >= retries || ctx.Err() != nil {
+			return err
+		}
+	}
+}
+
+func uploadFile(ctx context.Context, uploader *s3manager.Uploader, bucket, key, path, sum string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = uploader.UploadWithContext(ctx, &s3manager.UploadInput{
+		Bucket:      aws.String(bucket),
+		Key:         aws.String(key),
+		Body:        f,
+		ContentType: aws.String("application/gzip"),
+		Metadata:    map[string]*string{"sha256": aws.String(sum)},
+	})
+	return err
+}
+
+// uploaded checks that the object key has the expected size and checksum.
+func uploaded(ctx context.Context, svc *s3.S3, bucket, key string, size int64, sum string) error {
+	head, err := svc.HeadObjectWithContext(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return err
+	}
+	if aws.Int64Value(head.ContentLength) != size {
+		return fmt.Errorf("size mismatch: expected %d, got %d", size, aws.Int64Value(head.ContentLength))
+	}
+	// Metadata keys are returned in the canonical form of HTTP headers.
+	for name, value := range head.Metadata {
+		if strings.EqualFold(name, "sha256") {
+			if aws.StringValue(value) != sum {
+				return fmt.Errorf("checksum mismatch: expected %s, got %s", sum, aws.StringValue(value))
+			}
+			return nil
+		}
+	}
+	return errors.New("no checksum found")
+}
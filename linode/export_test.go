@@ -0,0 +1,67 @@
+package linode
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestChunkChecksum(t *testing.T) {
+	var buf bytes.Buffer
+	var offsets []int64
+	for _, chunk := range []string{"first chunk", "second chunk"} {
+		offsets = append(offsets, int64(buf.Len()))
+		gz := gzip.NewWriter(&buf)
+		gz.Write([]byte(chunk))
+		gz.Close()
+	}
+	offsets = append(offsets, int64(buf.Len()))
+
+	sum, err := chunkChecksum(bytes.NewReader(buf.Bytes()), offsets[1], offsets[2])
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+	expected := sha256.Sum256([]byte("second chunk"))
+	if sum != hex.EncodeToString(expected[:]) {
+		t.Errorf("bad checksum: %s", sum)
+	}
+
+	if _, err := chunkChecksum(bytes.NewReader(buf.Bytes()), offsets[0], offsets[1]-4); err == nil {
+		t.Error("truncated chunk should have error")
+	}
+}
+
+func TestWriteChecksumFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "packer-linode")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "image.img.gz")
+	if err := ioutil.WriteFile(path, []byte("image"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	sum, err := fileChecksum(path)
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+
+	checksumPath, err := writeChecksumFile(path, sum)
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+	data, err := ioutil.ReadFile(checksumPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := sha256.Sum256([]byte("image"))
+	if line := string(data); line != hex.EncodeToString(expected[:])+"  image.img.gz\n" {
+		t.Errorf("bad checksum file: %q", line)
+	}
+}
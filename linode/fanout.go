@@ -0,0 +1,188 @@
+package linode
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/hashicorp/packer/packer"
+	"github.com/linode/linodego"
+)
+
+// targets returns the configuration of each build to run: one for each
+// combination of regions and instance_types. Each build gets its own instance
+// label and log files.
+func (c *Config) targets() []*Config {
+	regions := c.Regions
+	if len(regions) == 0 {
+		regions = []string{c.Region}
+	}
+	instanceTypes := c.InstanceTypes
+	if len(instanceTypes) == 0 {
+		instanceTypes = []string{c.InstanceType}
+	}
+
+	var targets []*Config
+	for _, region := range regions {
+		for _, instanceType := range instanceTypes {
+			target := *c
+			target.Region = region
+			target.InstanceType = instanceType
+			targets = append(targets, &target)
+		}
+	}
+	if len(targets) == 1 {
+		return targets
+	}
+
+	for i, target := range targets {
+		target.target = target.Region + "-" + target.InstanceType
+		target.Label = fmt.Sprintf("%s-%d", c.Label, i+1)
+		target.ConsoleLogPath = targetPath(c.ConsoleLogPath, target.target)
+		target.MetricsLogPath = targetPath(c.MetricsLogPath, target.target)
+		target.ExportPath = targetPath(c.ExportPath, target.target)
+		target.ExportObjectKey = targetPath(c.ExportObjectKey, target.target)
+	}
+	return targets
+}
+
+// debugKeyPath returns where the temporary SSH key is saved.
+func (c *Config) debugKeyPath() string {
+	if c.target != "" {
+		return fmt.Sprintf("linode_%s_%s.pem", c.PackerBuildName, c.target)
+	}
+	return fmt.Sprintf("linode_%s.pem", c.PackerBuildName)
+}
+
+// targetPath inserts the target in the name of the file at path, before its
+// extension.
+func targetPath(path, target string) string {
+	if path == "" {
+		return ""
+	}
+	ext := filepath.Ext(path)
+	return strings.TrimSuffix(path, ext) + "-" + target + ext
+}
+
+// runTargets runs the builds of targets concurrently. If a build fails, the
+// others are cancelled and the images of the builds that succeeded are
+// deleted.
+func runTargets(ctx context.Context, ui packer.Ui, hook packer.Hook, targets []*Config) (packer.Artifact, error) {
+	if err := checkTargetsCost(ctx, ui, targets); err != nil {
+		ui.Error(err.Error())
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	artifacts := make([]*Artifact, len(targets))
+	errs := make([]error, len(targets))
+
+	var wg sync.WaitGroup
+	for i, target := range targets {
+		wg.Add(1)
+		go func(i int, target *Config) {
+			defer wg.Done()
+			targetUi := &prefixedUi{Ui: ui, prefix: fmt.Sprintf("[%s] ", target.target)}
+			artifacts[i], errs[i] = run(ctx, targetUi, hook, target)
+			if errs[i] != nil {
+				cancel()
+			}
+		}(i, target)
+	}
+	wg.Wait()
+
+	composite := new(CompositeArtifact)
+	var buildErrs *packer.MultiError
+	for i := range targets {
+		if errs[i] != nil {
+			buildErrs = packer.MultiErrorAppend(buildErrs, fmt.Errorf("%s: %s", targets[i].target, errs[i]))
+		} else {
+			composite.Artifacts = append(composite.Artifacts, artifacts[i])
+		}
+	}
+
+	if buildErrs != nil {
+		if len(composite.Artifacts) > 0 {
+			ui.Say("Deleting the images of the builds that succeeded...")
+			if err := composite.Destroy(); err != nil {
+				ui.Error(err.Error())
+			}
+		}
+		return nil, buildErrs
+	}
+	return composite, nil
+}
+
+// checkTargetsCost fails if the estimated cost of all the builds together is
+// higher than max_build_cost, before any instance is created. Each build still
+// checks its own cost.
+func checkTargetsCost(ctx context.Context, ui packer.Ui, targets []*Config) error {
+	c := targets[0]
+	if c.MaxBuildCost <= 0 {
+		return nil
+	}
+
+	httpClient, err := newLinodeHTTPClient(c)
+	if err != nil {
+		return err
+	}
+	client := newLinodeClient(c, httpClient)
+
+	instanceTypes := make(map[string]*linodego.LinodeType)
+	for _, target := range targets {
+		if _, ok := instanceTypes[target.InstanceType]; ok {
+			continue
+		}
+		instanceType, err := client.GetType(ctx, target.InstanceType)
+		if err != nil {
+			return errors.New("Error looking up instance type: " + err.Error())
+		}
+		instanceTypes[target.InstanceType] = instanceType
+	}
+
+	cost := targetsCost(targets, instanceTypes)
+	ui.Say(fmt.Sprintf("Estimated cost of the %d builds: $%.4f", len(targets), cost))
+	if cost > c.MaxBuildCost {
+		return fmt.Errorf("Error: the estimated cost of the %d builds, $%.4f, exceeds max_build_cost ($%.4f)",
+			len(targets), cost, c.MaxBuildCost)
+	}
+	return nil
+}
+
+// targetsCost returns the estimated cost of running the builds of targets,
+// given the instance types they run on.
+func targetsCost(targets []*Config, instanceTypes map[string]*linodego.LinodeType) float64 {
+	var cost float64
+	for _, target := range targets {
+		cost += estimateCost(instanceTypes[target.InstanceType], target.expectedBuildDuration)
+	}
+	return cost
+}
+
+// prefixedUi prefixes the output of a build so that concurrent builds can be
+// told apart.
+type prefixedUi struct {
+	packer.Ui
+	prefix string
+}
+
+func (u *prefixedUi) Ask(query string) (string, error) {
+	return u.Ui.Ask(u.prefix + query)
+}
+
+func (u *prefixedUi) Say(message string) {
+	u.Ui.Say(u.prefix + message)
+}
+
+func (u *prefixedUi) Message(message string) {
+	u.Ui.Message(u.prefix + message)
+}
+
+func (u *prefixedUi) Error(message string) {
+	u.Ui.Error(u.prefix + message)
+}
@@ -0,0 +1,70 @@
+package linode
+
+import (
+	"testing"
+	"time"
+
+	"github.com/linode/linodego"
+)
+
+func TestConfigTargets(t *testing.T) {
+	c := &Config{
+		Label:          "packer-123",
+		Region:         "us-east",
+		InstanceType:   "g6-nanode-1",
+		ConsoleLogPath: "console.log",
+	}
+	c.PackerBuildName = "linode"
+
+	targets := c.targets()
+	if len(targets) != 1 || targets[0].Label != "packer-123" || targets[0].ConsoleLogPath != "console.log" {
+		t.Fatalf("bad: %#v", targets)
+	}
+	if path := targets[0].debugKeyPath(); path != "linode_linode.pem" {
+		t.Errorf("bad key path: %s", path)
+	}
+
+	c.Region = ""
+	c.Regions = []string{"us-east", "eu-west"}
+	targets = c.targets()
+	if len(targets) != 2 {
+		t.Fatalf("found %d targets, expected 2", len(targets))
+	}
+
+	target := targets[1]
+	if target.Region != "eu-west" || target.InstanceType != "g6-nanode-1" {
+		t.Errorf("bad target: %s %s", target.Region, target.InstanceType)
+	}
+	if target.Label != "packer-123-2" {
+		t.Errorf("bad label: %s", target.Label)
+	}
+	if target.ConsoleLogPath != "console-eu-west-g6-nanode-1.log" {
+		t.Errorf("bad console log path: %s", target.ConsoleLogPath)
+	}
+	if target.MetricsLogPath != "" {
+		t.Errorf("bad metrics log path: %s", target.MetricsLogPath)
+	}
+	if path := target.debugKeyPath(); path != "linode_linode_eu-west-g6-nanode-1.pem" {
+		t.Errorf("bad key path: %s", path)
+	}
+	if c.Label != "packer-123" || c.Region != "" {
+		t.Errorf("config should not be modified: %#v", c)
+	}
+}
+
+func TestTargetsCost(t *testing.T) {
+	c := &Config{
+		Regions:               []string{"us-east", "eu-west"},
+		InstanceTypes:         []string{"g6-nanode-1", "g6-standard-1"},
+		expectedBuildDuration: 90 * time.Minute,
+	}
+	instanceTypes := map[string]*linodego.LinodeType{
+		"g6-nanode-1":   {Price: &linodego.LinodePrice{Hourly: 0.5}},
+		"g6-standard-1": {Price: &linodego.LinodePrice{Hourly: 1}},
+	}
+
+	// Each of the 4 builds is billed 2 hours.
+	if cost := targetsCost(c.targets(), instanceTypes); cost != 6 {
+		t.Fatalf("found %v, expected 6", cost)
+	}
+}
@@ -0,0 +1,71 @@
+package linode
+
+import (
+	"encoding/json"
+	"regexp"
+	"sort"
+
+	"github.com/linode/linodego"
+)
+
+// ImageFilterOptions selects the source image among the images available to
+// the account. There is no tags criterion, as linodego doesn't expose the
+// tags of images.
+type ImageFilterOptions struct {
+	Label      string `mapstructure:"label"`
+	Vendor     string `mapstructure:"vendor"`
+	IsPublic   *bool  `mapstructure:"is_public"`
+	CreatedBy  string `mapstructure:"created_by"`
+	MostRecent bool   `mapstructure:"most_recent"`
+}
+
+// Empty reports whether no filter criteria are set.
+func (f *ImageFilterOptions) Empty() bool {
+	return f.Label == "" && f.Vendor == "" && f.IsPublic == nil && f.CreatedBy == ""
+}
+
+// listOptions narrows down the images listed by the API with the filters it
+// supports. The rest is matched by matchImages.
+func (f *ImageFilterOptions) listOptions() *linodego.ListOptions {
+	filter := make(map[string]interface{})
+	if f.IsPublic != nil {
+		filter["is_public"] = *f.IsPublic
+	}
+	if f.Vendor != "" {
+		filter["vendor"] = f.Vendor
+	}
+	if len(filter) == 0 {
+		return nil
+	}
+
+	rawFilter, _ := json.Marshal(filter)
+	return linodego.NewListOptions(0, string(rawFilter))
+}
+
+// matchImages returns the images matching the filter, most recent first.
+func (f *ImageFilterOptions) matchImages(images []linodego.Image) ([]linodego.Image, error) {
+	labelRe, err := regexp.Compile(f.Label)
+	if err != nil {
+		return nil, err
+	}
+
+	var matches []linodego.Image
+	for _, image := range images {
+		switch {
+		case !labelRe.MatchString(image.Label):
+		case f.Vendor != "" && image.Vendor != f.Vendor:
+		case f.IsPublic != nil && image.IsPublic != *f.IsPublic:
+		case f.CreatedBy != "" && image.CreatedBy != f.CreatedBy:
+		default:
+			matches = append(matches, image)
+		}
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		if matches[i].Created == nil || matches[j].Created == nil {
+			return matches[j].Created == nil && matches[i].Created != nil
+		}
+		return matches[i].Created.After(*matches[j].Created)
+	})
+	return matches, nil
+}
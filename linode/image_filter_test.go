@@ -0,0 +1,63 @@
+package linode
+
+import (
+	"testing"
+	"time"
+
+	"github.com/linode/linodego"
+)
+
+func testImages() []linodego.Image {
+	older := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	newer := time.Date(2019, 2, 1, 0, 0, 0, 0, time.UTC)
+
+	return []linodego.Image{
+		{ID: "private/1", Label: "base-debian", CreatedBy: "builder", Created: &older},
+		{ID: "private/2", Label: "base-debian", CreatedBy: "builder", Created: &newer},
+		{ID: "private/3", Label: "app-debian", CreatedBy: "someone", Created: &newer},
+		{ID: "linode/debian9", Label: "Debian 9", Vendor: "Debian", IsPublic: true, CreatedBy: "linode"},
+	}
+}
+
+func TestImageFilterOptions_Empty(t *testing.T) {
+	if f := (&ImageFilterOptions{MostRecent: true}); !f.Empty() {
+		t.Fatal("filter without criteria should be empty")
+	}
+	if f := (&ImageFilterOptions{Label: "^base-"}); f.Empty() {
+		t.Fatal("filter with a label should not be empty")
+	}
+}
+
+func TestImageFilterOptions_MatchImages(t *testing.T) {
+	isPublic := false
+	f := &ImageFilterOptions{Label: "^base-", IsPublic: &isPublic}
+
+	matches, err := f.matchImages(testImages())
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+	if len(matches) != 2 {
+		t.Fatalf("expected 2 matches: %#v", matches)
+	}
+	if matches[0].ID != "private/2" {
+		t.Fatalf("most recent image should be first: %s", matches[0].ID)
+	}
+
+	f = &ImageFilterOptions{Vendor: "Debian"}
+	matches, err = f.matchImages(testImages())
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+	if len(matches) != 1 || matches[0].ID != "linode/debian9" {
+		t.Fatalf("bad: %#v", matches)
+	}
+
+	f = &ImageFilterOptions{CreatedBy: "someone"}
+	matches, err = f.matchImages(testImages())
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+	if len(matches) != 1 || matches[0].ID != "private/3" {
+		t.Fatalf("bad: %#v", matches)
+	}
+}
@@ -0,0 +1,163 @@
+package linode
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/hashicorp/packer/template/interpolate"
+	"github.com/hashicorp/packer/version"
+	"github.com/linode/linodego"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	maxImageLabelLength       = 65
+	maxImageDescriptionLength = 65000
+)
+
+// gitSHAVariables are the environment variables CI systems commonly expose
+// the commit being built in.
+var gitSHAVariables = []string{"GIT_COMMIT", "GIT_SHA", "CIRCLE_SHA1", "GITHUB_SHA", "CI_COMMIT_SHA", "TRAVIS_COMMIT"}
+
+var invalidImageLabelRe = regexp.MustCompile(`[^[:alnum:] ._-]+`)
+
+// buildInfo is the data available to the image_label and image_description
+// templates.
+type buildInfo struct {
+	BuildName        string
+	BuildTag         string
+	SourceImage      string
+	SourceImageLabel string
+	Region           string
+	InstanceType     string
+	GitSHA           string
+}
+
+func newBuildInfo(c *Config, sourceImage *linodego.Image) *buildInfo {
+	info := &buildInfo{
+		BuildName:    c.PackerBuildName,
+		BuildTag:     c.BuildTag,
+		SourceImage:  c.Image,
+		Region:       c.Region,
+		InstanceType: c.InstanceType,
+	}
+	if sourceImage != nil {
+		info.SourceImageLabel = sourceImage.Label
+	}
+	for _, v := range gitSHAVariables {
+		if sha := os.Getenv(v); sha != "" {
+			info.GitSHA = sha
+			break
+		}
+	}
+	return info
+}
+
+// imageMetadata is the structured block added to the image description when
+// image_description_metadata is set.
+type imageMetadata struct {
+	BuildName     string `json:"build_name" yaml:"build_name"`
+	BuildTime     string `json:"build_time" yaml:"build_time"`
+	PackerVersion string `json:"packer_version" yaml:"packer_version"`
+	SourceImage   string `json:"source_image" yaml:"source_image"`
+	Region        string `json:"region" yaml:"region"`
+	InstanceType  string `json:"instance_type" yaml:"instance_type"`
+	GitSHA        string `json:"git_sha,omitempty" yaml:"git_sha,omitempty"`
+}
+
+// renderImageLabel renders the image_label template and makes the result a
+// valid image label. Labels that are too long are truncated and suffixed with
+// a hash of the full label, so that they stay unique.
+func renderImageLabel(c *Config, info *buildInfo) (string, error) {
+	ctx := c.ctx
+	ctx.Data = info
+	label, err := interpolate.Render(c.rawImageLabel, &ctx)
+	if err != nil {
+		return "", err
+	}
+
+	label = invalidImageLabelRe.ReplaceAllString(strings.TrimSpace(label), "-")
+	if len(label) > maxImageLabelLength {
+		sum := sha256.Sum256([]byte(label))
+		label = fmt.Sprintf("%s-%x", label[:maxImageLabelLength-9], sum[:4])
+	}
+	return label, nil
+}
+
+// renderImageDescription renders the image_description template and appends
+// the metadata block and the lineage of the image. The rendered template is
+// truncated if needed so that the rest always fits.
+func renderImageDescription(c *Config, info *buildInfo, parent *linodego.Image) (string, error) {
+	ctx := c.ctx
+	ctx.Data = info
+	description, err := interpolate.Render(c.rawDescription, &ctx)
+	if err != nil {
+		return "", err
+	}
+
+	var blocks []string
+	if c.ImageDescriptionMetadata != "" {
+		metadata, err := renderImageMetadata(c.ImageDescriptionMetadata, info)
+		if err != nil {
+			return "", err
+		}
+		blocks = append(blocks, metadata)
+	}
+	if lines := lineage(parent, c.BuildTag); lines != "" {
+		blocks = append(blocks, lines)
+	}
+	if len(blocks) == 0 {
+		return truncate(description, maxImageDescriptionLength), nil
+	}
+
+	suffix := strings.Join(blocks, "\n\n")
+	if description == "" {
+		return suffix, nil
+	}
+	suffix = "\n\n" + suffix
+	return truncate(description, maxImageDescriptionLength-len(suffix)) + suffix, nil
+}
+
+// truncate cuts s to at most n bytes, without splitting a UTF-8 character.
+func truncate(s string, n int) string {
+	if n < 0 {
+		n = 0
+	}
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
+
+func renderImageMetadata(format string, info *buildInfo) (string, error) {
+	metadata := &imageMetadata{
+		BuildName:     info.BuildName,
+		BuildTime:     time.Now().UTC().Format(time.RFC3339),
+		PackerVersion: version.FormattedVersion(),
+		SourceImage:   info.SourceImage,
+		Region:        info.Region,
+		InstanceType:  info.InstanceType,
+		GitSHA:        info.GitSHA,
+	}
+
+	var out []byte
+	var err error
+	switch format {
+	case "json":
+		out, err = json.MarshalIndent(metadata, "", "  ")
+	case "yaml":
+		out, err = yaml.Marshal(metadata)
+	default:
+		err = fmt.Errorf("unknown metadata format: %s", format)
+	}
+	return strings.TrimSpace(string(out)), err
+}
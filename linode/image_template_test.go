@@ -0,0 +1,94 @@
+package linode
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/linode/linodego"
+)
+
+func TestRenderImageLabel(t *testing.T) {
+	c := &Config{rawImageLabel: "app {{ .Region }}/{{ .InstanceType }}"}
+	info := &buildInfo{Region: "us-east", InstanceType: "g6-nanode-1"}
+
+	label, err := renderImageLabel(c, info)
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+	if label != "app us-east-g6-nanode-1" {
+		t.Fatalf("bad: %s", label)
+	}
+}
+
+func TestRenderImageLabel_Truncate(t *testing.T) {
+	c := &Config{rawImageLabel: strings.Repeat("a", 100)}
+
+	label, err := renderImageLabel(c, &buildInfo{})
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+	if len(label) != maxImageLabelLength {
+		t.Fatalf("label should be truncated to %d characters: %s", maxImageLabelLength, label)
+	}
+
+	c.rawImageLabel = strings.Repeat("a", 99)
+	other, err := renderImageLabel(c, &buildInfo{})
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+	if label == other {
+		t.Fatalf("truncated labels should stay unique: %s", label)
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	cases := map[int]string{
+		0: "",
+		1: "a",
+		2: "a",
+		3: "aé",
+		9: "aéb",
+	}
+	for n, expected := range cases {
+		if s := truncate("aéb", n); s != expected {
+			t.Errorf("%d: found %q, expected %q", n, s, expected)
+		}
+	}
+}
+
+func TestRenderImageDescription(t *testing.T) {
+	c := &Config{
+		rawDescription:           "Built from {{ .SourceImage }}",
+		ImageDescriptionMetadata: "json",
+		BuildTag:                 "runtime",
+	}
+	info := &buildInfo{SourceImage: "private/1", Region: "us-east"}
+	parent := &linodego.Image{ID: "private/1", Label: "base"}
+
+	description, err := renderImageDescription(c, info, parent)
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+
+	if !strings.HasPrefix(description, "Built from private/1\n\n{") {
+		t.Fatalf("bad: %s", description)
+	}
+	if !strings.Contains(description, `"region": "us-east"`) {
+		t.Fatalf("metadata should be included: %s", description)
+	}
+	if lineageValue(description, buildTagKey) != "runtime" {
+		t.Fatalf("lineage should be included: %s", description)
+	}
+
+	c.rawDescription = strings.Repeat("a", maxImageDescriptionLength)
+	description, err = renderImageDescription(c, info, parent)
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+	if len(description) != maxImageDescriptionLength {
+		t.Fatalf("description should be truncated: %d", len(description))
+	}
+	if lineageValue(description, buildTagKey) != "runtime" {
+		t.Fatal("lineage should survive truncation")
+	}
+}
@@ -0,0 +1,114 @@
+package linode
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/packer/helper/multistep"
+	"github.com/hashicorp/packer/packer"
+	"github.com/linode/linodego"
+)
+
+// keepInstance keeps the instance of a failed build for debugging. Access to
+// the instance is restricted to the operator and the connection details are
+// printed. It returns whether the instance should be deleted, which is the
+// case once debug_hold_duration expires.
+func keepInstance(state multistep.StateBag, instance *linodego.Instance) bool {
+	c := state.Get("config").(*Config)
+	ui := state.Get("ui").(packer.Ui)
+
+	ui.Say(fmt.Sprintf("Keeping Linode %d for debugging...", instance.ID))
+
+	// The rescue environment is where the firewall has to be set up, not in
+	// the chroot.
+	rawComm, ok := state.GetOk("rescue_communicator")
+	if !ok {
+		rawComm, ok = state.GetOk("communicator")
+	}
+	if ok {
+		if ip, err := restrictAccess(rawComm.(packer.Communicator)); err != nil {
+			ui.Error(fmt.Sprintf("Unable to restrict access to the Linode: %s", err))
+		} else {
+			ui.Message(fmt.Sprintf("Access is restricted to %s", ip))
+		}
+	} else {
+		ui.Message("Not connected to the Linode, access to it is not restricted")
+	}
+
+	if len(instance.IPv4) > 0 {
+		host := instance.IPv4[0].String()
+		ui.Message(fmt.Sprintf("IP address: %s", host))
+		if path, ok := state.GetOk("ssh_private_key_path"); ok {
+			ui.Message(fmt.Sprintf("Connect with: ssh -i %s -p %d %s@%s",
+				path.(string), c.Comm.SSHPort, c.Comm.SSHUsername, host))
+		}
+	}
+
+	if c.debugHoldDuration == 0 {
+		ui.Message("The Linode has to be deleted manually")
+		state.Put("instance_kept", true)
+		return false
+	}
+
+	ui.Message(fmt.Sprintf("The Linode will be deleted in %s, interrupt to delete it now", c.debugHoldDuration))
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	defer signal.Stop(interrupt)
+
+	select {
+	case <-time.After(c.debugHoldDuration):
+	case <-interrupt:
+	}
+	return true
+}
+
+// restrictAccess drops incoming traffic on the instance, except from the
+// address the communicator connects from, which is returned.
+func restrictAccess(comm packer.Communicator) (string, error) {
+	out, err := remoteOutput(comm, `echo "${SSH_CLIENT%% *}"`)
+	if err != nil {
+		return "", err
+	}
+	ip := net.ParseIP(strings.TrimSpace(out))
+	if ip == nil {
+		return "", fmt.Errorf("unable to determine the operator address from %q", out)
+	}
+
+	allow, deny := "iptables", "ip6tables"
+	if ip.To4() == nil {
+		allow, deny = deny, allow
+	}
+	command := strings.Join([]string{
+		firewallRules(allow, ip.String()),
+		fmt.Sprintf("if command -v %s >/dev/null; then %s; fi", deny, firewallRules(deny, "")),
+	}, " && ")
+
+	if _, err := remoteOutput(comm, command); err != nil {
+		return "", err
+	}
+	return ip.String(), nil
+}
+
+// firewallRules returns the commands that drop incoming traffic except on the
+// loopback interface, for established connections and from source.
+func firewallRules(iptables, source string) string {
+	rules := []string{
+		"-F INPUT",
+		"-A INPUT -i lo -j ACCEPT",
+		"-A INPUT -m conntrack --ctstate ESTABLISHED,RELATED -j ACCEPT",
+	}
+	if source != "" {
+		rules = append(rules, fmt.Sprintf("-A INPUT -s %s -j ACCEPT", source))
+	}
+	rules = append(rules, "-P INPUT DROP")
+
+	commands := make([]string, len(rules))
+	for i, rule := range rules {
+		commands[i] = iptables + " " + rule
+	}
+	return strings.Join(commands, " && ")
+}
@@ -0,0 +1,44 @@
+package linode
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/packer/packer"
+)
+
+func TestRestrictAccess(t *testing.T) {
+	comm := &packer.MockCommunicator{StartStdout: "203.0.113.5\n"}
+
+	ip, err := restrictAccess(comm)
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+	if ip != "203.0.113.5" {
+		t.Errorf("bad: %s", ip)
+	}
+
+	command := comm.StartCmd.Command
+	if !strings.Contains(command, "iptables -A INPUT -s 203.0.113.5 -j ACCEPT") {
+		t.Errorf("operator should be allowed: %s", command)
+	}
+	if !strings.Contains(command, "then ip6tables -F INPUT") || strings.Contains(command, "ip6tables -A INPUT -s") {
+		t.Errorf("IPv6 should be denied: %s", command)
+	}
+
+	comm = &packer.MockCommunicator{StartStdout: "\n"}
+	if _, err := restrictAccess(comm); err == nil {
+		t.Fatal("should have error")
+	}
+}
+
+func TestFirewallRules(t *testing.T) {
+	expected := "iptables -F INPUT && " +
+		"iptables -A INPUT -i lo -j ACCEPT && " +
+		"iptables -A INPUT -m conntrack --ctstate ESTABLISHED,RELATED -j ACCEPT && " +
+		"iptables -A INPUT -s 2001:db8::1 -j ACCEPT && " +
+		"iptables -P INPUT DROP"
+	if rules := firewallRules("iptables", "2001:db8::1"); rules != expected {
+		t.Errorf("bad: %s", rules)
+	}
+}
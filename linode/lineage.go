@@ -0,0 +1,40 @@
+package linode
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/linode/linodego"
+)
+
+// The lineage of an image is recorded as "key: value" lines at the end of its
+// description, so that images can be traced back to their base and found by
+// their build tag.
+const (
+	parentImageKey = "packer_parent_image"
+	buildTagKey    = "packer_build_tag"
+)
+
+// lineage returns the lines recording the lineage of an image built from
+// parent. Public images are where every lineage starts, so only private
+// parents are recorded.
+func lineage(parent *linodego.Image, buildTag string) string {
+	var lines []string
+	if parent != nil && !parent.IsPublic {
+		lines = append(lines, fmt.Sprintf("%s: %s (%s)", parentImageKey, parent.ID, parent.Label))
+	}
+	if buildTag != "" {
+		lines = append(lines, fmt.Sprintf("%s: %s", buildTagKey, buildTag))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// lineageValue returns the value recorded under key in an image description.
+func lineageValue(description, key string) string {
+	for _, line := range strings.Split(description, "\n") {
+		if strings.HasPrefix(line, key+": ") {
+			return strings.TrimPrefix(line, key+": ")
+		}
+	}
+	return ""
+}
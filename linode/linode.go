@@ -1,30 +1,119 @@
 package linode
 
 import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
 	"fmt"
+	"io/ioutil"
+	"net"
 	"net/http"
+	"strings"
+	"time"
 
 	"github.com/hashicorp/packer/version"
 	"github.com/linode/linodego"
 	"golang.org/x/oauth2"
 )
 
-func newLinodeClient(pat string) linodego.Client {
-	tokenSource := oauth2.StaticTokenSource(&oauth2.Token{AccessToken: pat})
+const (
+	defaultAPIURL     = "https://api.linode.com"
+	defaultAPIVersion = "v4"
+)
+
+func newLinodeHTTPClient(c *Config) (*http.Client, error) {
+	tokenSource := oauth2.StaticTokenSource(&oauth2.Token{AccessToken: c.PersonalAccessToken})
 
 	oauthTransport := &oauth2.Transport{
 		Source: tokenSource,
 	}
-	oauth2Client := &http.Client{
+	if c.APICAFile != "" {
+		pool, err := loadCAFile(c.APICAFile)
+		if err != nil {
+			return nil, err
+		}
+		oauthTransport.Base = &http.Transport{
+			Proxy: http.ProxyFromEnvironment,
+			DialContext: (&net.Dialer{
+				Timeout:   30 * time.Second,
+				KeepAlive: 30 * time.Second,
+			}).DialContext,
+			TLSClientConfig:       &tls.Config{RootCAs: pool},
+			MaxIdleConns:          100,
+			IdleConnTimeout:       90 * time.Second,
+			TLSHandshakeTimeout:   10 * time.Second,
+			ExpectContinueTimeout: 1 * time.Second,
+		}
+	}
+	return &http.Client{
 		Transport: oauthTransport,
+	}, nil
+}
+
+func newLinodeClient(c *Config, httpClient *http.Client) linodego.Client {
+	client := linodego.NewClient(httpClient)
+	client.SetUserAgent(userAgent())
+	client.SetBaseURL(c.apiBaseURL())
+	return client
+}
+
+// loadCAFile returns a pool of the PEM encoded certificates in path.
+func loadCAFile(path string) (*x509.CertPool, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("no certificate found in %s", path)
 	}
+	return pool, nil
+}
 
-	client := linodego.NewClient(oauth2Client)
+// apiBaseURL returns the URL of the configured API version.
+func (c *Config) apiBaseURL() string {
+	return strings.TrimSuffix(c.APIURL, "/") + "/" + c.APIVersion
+}
 
+func userAgent() string {
 	projectURL := "https://www.packer.io"
-	userAgent := fmt.Sprintf("Packer/%s (+%s) linodego/%s",
+	return fmt.Sprintf("Packer/%s (+%s) linodego/%s",
 		version.FormattedVersion(), projectURL, linodego.Version)
+}
 
-	client.SetUserAgent(userAgent)
-	return client
+// getJSON decodes the response of a GET request to the API. It is used for
+// the endpoints and fields that linodego does not expose. out is left as is
+// if the response has no content.
+func getJSON(ctx context.Context, httpClient *http.Client, baseURL, path string, out interface{}) error {
+	req, err := http.NewRequest("GET", baseURL+path, nil)
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("User-Agent", userAgent())
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode == http.StatusNoContent {
+		return nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return &linodego.Error{
+			Response: resp,
+			Code:     resp.StatusCode,
+			Message:  fmt.Sprintf("[%03d] %s", resp.StatusCode, body),
+		}
+	}
+
+	return json.Unmarshal(body, out)
 }
@@ -0,0 +1,66 @@
+package linode
+
+import (
+	"context"
+	"encoding/pem"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestGetJSON_CAFile(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v4beta/regions" {
+			t.Errorf("bad path: %s", r.URL.Path)
+		}
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			t.Errorf("bad authorization: %s", r.Header.Get("Authorization"))
+		}
+		w.Write([]byte(`{"data": [{"id": "us-east"}]}`))
+	}))
+	defer server.Close()
+
+	dir, err := ioutil.TempDir("", "packer")
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	caFile := filepath.Join(dir, "ca.pem")
+	cert := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	if err := ioutil.WriteFile(caFile, cert, 0600); err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+
+	c := &Config{
+		PersonalAccessToken: "secret",
+		APIURL:              server.URL + "/",
+		APIVersion:          "v4beta",
+	}
+
+	// The server certificate is not trusted without the CA file.
+	httpClient, err := newLinodeHTTPClient(c)
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+	var regions struct {
+		Data []catalogRegion `json:"data"`
+	}
+	if err := getJSON(context.Background(), httpClient, c.apiBaseURL(), "/regions", &regions); err == nil {
+		t.Fatal("should have error")
+	}
+
+	c.APICAFile = caFile
+	if httpClient, err = newLinodeHTTPClient(c); err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+	if err := getJSON(context.Background(), httpClient, c.apiBaseURL(), "/regions", &regions); err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+	if len(regions.Data) != 1 || regions.Data[0].ID != "us-east" {
+		t.Errorf("bad: %#v", regions)
+	}
+}
@@ -0,0 +1,99 @@
+package linode
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strings"
+)
+
+// manifest is the file written by Packer's manifest post-processor.
+type manifest struct {
+	Builds      []manifestBuild `json:"builds"`
+	LastRunUUID string          `json:"last_run_uuid"`
+}
+
+type manifestBuild struct {
+	Name          string `json:"name"`
+	BuilderType   string `json:"builder_type"`
+	BuildTime     int64  `json:"build_time"`
+	ArtifactID    string `json:"artifact_id"`
+	PackerRunUUID string `json:"packer_run_uuid"`
+}
+
+func readManifest(path string) (*manifest, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	m := new(manifest)
+	if err := json.Unmarshal(data, m); err != nil {
+		return nil, fmt.Errorf("unable to parse manifest %s: %s", path, err)
+	}
+	return m, nil
+}
+
+// imageID returns the image built by the named build, or by the most recent
+// Linode build if name is empty. Builds of the last run are preferred.
+func (m *manifest) imageID(name string) (string, error) {
+	var found *manifestBuild
+	for i := range m.Builds {
+		build := &m.Builds[i]
+		if build.BuilderType != "linode" || (name != "" && build.Name != name) {
+			continue
+		}
+		if found == nil || found.PackerRunUUID != m.LastRunUUID || build.PackerRunUUID == m.LastRunUUID {
+			found = build
+		}
+	}
+
+	if found == nil {
+		if name != "" {
+			return "", fmt.Errorf("no Linode build named %s found in manifest", name)
+		}
+		return "", fmt.Errorf("no Linode build found in manifest")
+	}
+	return found.ArtifactID, nil
+}
+
+// regionImage is an image of a build fanned out to several regions.
+type regionImage struct {
+	Region  string
+	ImageID string
+}
+
+// parseCompositeID returns the images listed in the ID of a CompositeArtifact,
+// such as "us-east:private/1,eu-west:private/2".
+func parseCompositeID(id string) ([]regionImage, error) {
+	var images []regionImage
+	for _, image := range strings.Split(id, ",") {
+		parts := strings.SplitN(image, ":", 2)
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return nil, fmt.Errorf("invalid artifact ID in manifest: %s", id)
+		}
+		images = append(images, regionImage{Region: parts[0], ImageID: parts[1]})
+	}
+	return images, nil
+}
+
+// regionImageID returns the image to use in region from an artifact ID. The ID
+// of a build fanned out to several regions lists an image for each of them.
+func regionImageID(id, region string) (string, error) {
+	if !strings.Contains(id, ":") {
+		return id, nil
+	}
+	images, err := parseCompositeID(id)
+	if err != nil {
+		return "", err
+	}
+
+	var regions []string
+	for _, image := range images {
+		if image.Region == region {
+			return image.ImageID, nil
+		}
+		regions = append(regions, image.Region)
+	}
+	return "", fmt.Errorf("the build in the manifest has no image in region %s, only in %s",
+		region, strings.Join(regions, ", "))
+}
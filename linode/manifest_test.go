@@ -0,0 +1,72 @@
+package linode
+
+import (
+	"testing"
+
+	"github.com/linode/linodego"
+)
+
+func TestManifestImageID(t *testing.T) {
+	m := &manifest{
+		Builds: []manifestBuild{
+			{Name: "base", BuilderType: "linode", ArtifactID: "private/1", PackerRunUUID: "run-1"},
+			{Name: "base", BuilderType: "linode", ArtifactID: "private/2", PackerRunUUID: "run-2"},
+			{Name: "runtime", BuilderType: "linode", ArtifactID: "private/3", PackerRunUUID: "run-1"},
+			{Name: "base", BuilderType: "amazon-ebs", ArtifactID: "us-east-1:ami-1", PackerRunUUID: "run-2"},
+		},
+		LastRunUUID: "run-2",
+	}
+
+	if id, err := m.imageID(""); err != nil || id != "private/2" {
+		t.Fatalf("bad: %s %s", id, err)
+	}
+	if id, err := m.imageID("runtime"); err != nil || id != "private/3" {
+		t.Fatalf("bad: %s %s", id, err)
+	}
+	if _, err := m.imageID("app"); err == nil {
+		t.Fatal("should have error")
+	}
+}
+
+func TestRegionImageID(t *testing.T) {
+	if id, err := regionImageID("private/1", "us-east"); err != nil || id != "private/1" {
+		t.Fatalf("bad: %s %s", id, err)
+	}
+
+	composite := "us-east:private/1,eu-west:private/2"
+	if id, err := regionImageID(composite, "eu-west"); err != nil || id != "private/2" {
+		t.Fatalf("bad: %s %s", id, err)
+	}
+	if _, err := regionImageID(composite, "ap-south"); err == nil {
+		t.Fatal("should have error")
+	}
+	if _, err := regionImageID("us-east:private/1,private/2", "us-east"); err == nil {
+		t.Fatal("should have error")
+	}
+}
+
+func TestLineage(t *testing.T) {
+	parent := &linodego.Image{ID: "private/1", Label: "base"}
+
+	description := lineage(parent, "runtime")
+	expected := "packer_parent_image: private/1 (base)\npacker_build_tag: runtime"
+	if description != expected {
+		t.Fatalf("found %q, expected %q", description, expected)
+	}
+
+	if tag := lineageValue(description, buildTagKey); tag != "runtime" {
+		t.Fatalf("bad: %s", tag)
+	}
+	if value := lineageValue("My image", buildTagKey); value != "" {
+		t.Fatalf("bad: %s", value)
+	}
+
+	parent.IsPublic = true
+	if description := lineage(parent, ""); description != "" {
+		t.Fatalf("public parents should not be recorded: %s", description)
+	}
+
+	if description := lineage(nil, ""); description != "" {
+		t.Fatalf("bad: %s", description)
+	}
+}
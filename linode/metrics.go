@@ -0,0 +1,220 @@
+package linode
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/packer/helper/multistep"
+)
+
+// buildMetrics records how long each step takes and the API calls made
+// during it. Records are written as JSON lines to log, if set.
+type buildMetrics struct {
+	mu      sync.Mutex
+	start   time.Time
+	steps   []*stepMetrics
+	current *stepMetrics
+	log     io.Writer
+}
+
+type stepMetrics struct {
+	Step         string    `json:"step"`
+	Phase        string    `json:"phase"`
+	Start        time.Time `json:"start"`
+	End          time.Time `json:"end"`
+	DurationMS   int64     `json:"duration_ms"`
+	Action       string    `json:"action,omitempty"`
+	APICalls     int       `json:"api_calls"`
+	APILatencyMS int64     `json:"api_latency_ms"`
+}
+
+type apiCallMetrics struct {
+	Time       time.Time `json:"time"`
+	Step       string    `json:"step,omitempty"`
+	Method     string    `json:"method"`
+	Path       string    `json:"path"`
+	Status     int       `json:"status,omitempty"`
+	Error      string    `json:"error,omitempty"`
+	DurationMS int64     `json:"duration_ms"`
+}
+
+// buildSummary is the summary of the metrics added to the artifact state.
+type buildSummary struct {
+	DurationMS   int64          `json:"duration_ms"`
+	APICalls     int            `json:"api_calls"`
+	APILatencyMS int64          `json:"api_latency_ms"`
+	Steps        []*stepMetrics `json:"steps"`
+}
+
+func newBuildMetrics(log io.Writer) *buildMetrics {
+	return &buildMetrics{start: time.Now(), log: log}
+}
+
+func (m *buildMetrics) startStep(name, phase string) *stepMetrics {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s := &stepMetrics{Step: name, Phase: phase, Start: time.Now()}
+	m.steps = append(m.steps, s)
+	m.current = s
+	m.emit("step_start", struct {
+		Time  time.Time `json:"time"`
+		Step  string    `json:"step"`
+		Phase string    `json:"phase"`
+	}{s.Start, name, phase})
+	return s
+}
+
+func (m *buildMetrics) endStep(s *stepMetrics, action string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s.End = time.Now()
+	s.DurationMS = milliseconds(s.End.Sub(s.Start))
+	s.Action = action
+	if m.current == s {
+		m.current = nil
+	}
+	log.Printf("Step %s (%s) took %s with %d API calls", s.Step, s.Phase, s.End.Sub(s.Start), s.APICalls)
+	m.emit("step_end", s)
+}
+
+func (m *buildMetrics) recordAPICall(call *apiCallMetrics) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.current != nil {
+		call.Step = m.current.Step
+		m.current.APICalls++
+		m.current.APILatencyMS += call.DurationMS
+	}
+	m.emit("api_call", call)
+}
+
+func (m *buildMetrics) summary() *buildSummary {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	summary := &buildSummary{
+		DurationMS: milliseconds(time.Since(m.start)),
+		Steps:      m.steps,
+	}
+	for _, s := range m.steps {
+		summary.APICalls += s.APICalls
+		summary.APILatencyMS += s.APILatencyMS
+	}
+	return summary
+}
+
+// emit writes a record with its event name. m.mu must be held.
+func (m *buildMetrics) emit(event string, record interface{}) {
+	if m.log == nil {
+		return
+	}
+	data, err := json.Marshal(record)
+	if err != nil {
+		log.Printf("Unable to encode metrics: %s", err)
+		return
+	}
+	line := fmt.Sprintf(`{"event":%q`, event)
+	if len(data) > 2 {
+		line += "," + string(data[1:])
+	} else {
+		line += "}"
+	}
+	if _, err := io.WriteString(m.log, line+"\n"); err != nil {
+		log.Printf("Unable to write metrics: %s", err)
+	}
+}
+
+// transport wraps base so that API calls are recorded.
+func (m *buildMetrics) transport(base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &metricsTransport{base: base, metrics: m}
+}
+
+type metricsTransport struct {
+	base    http.RoundTripper
+	metrics *buildMetrics
+}
+
+func (t *metricsTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	resp, err := t.base.RoundTrip(req)
+
+	call := &apiCallMetrics{
+		Time:       start,
+		Method:     req.Method,
+		Path:       req.URL.Path,
+		DurationMS: milliseconds(time.Since(start)),
+	}
+	if err != nil {
+		call.Error = err.Error()
+	} else {
+		call.Status = resp.StatusCode
+	}
+	t.metrics.recordAPICall(call)
+
+	return resp, err
+}
+
+// timedStep records the time spent running and cleaning up Step, and traces
+// it if tracing is enabled.
+type timedStep struct {
+	multistep.Step
+	metrics *buildMetrics
+	tracer  *tracer
+}
+
+func (s *timedStep) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	name := stepName(s.Step)
+	m := s.metrics.startStep(name, "run")
+	span := s.tracer.startStep(name, "run")
+
+	action := s.Step.Run(ctx, state)
+
+	s.tracer.endStep(span, state, action != multistep.ActionContinue)
+	if action == multistep.ActionContinue {
+		s.metrics.endStep(m, "continue")
+	} else {
+		s.metrics.endStep(m, "halt")
+	}
+	return action
+}
+
+func (s *timedStep) Cleanup(state multistep.StateBag) {
+	name := stepName(s.Step)
+	m := s.metrics.startStep(name, "cleanup")
+	span := s.tracer.startStep(name, "cleanup")
+
+	s.Step.Cleanup(state)
+
+	s.tracer.endStep(span, state, false)
+	s.metrics.endStep(m, "")
+}
+
+// timeSteps reports whether steps are wrapped in timedStep. Packer names the
+// steps it pauses at in debug mode, or asks about when one fails, after their
+// type, so they are only wrapped when no step is named.
+func (c *Config) timeSteps() bool {
+	return !c.PackerDebug && c.PackerOnError != "ask"
+}
+
+// stepName returns the type name of step, such as stepCreateLinode.
+func stepName(step multistep.Step) string {
+	name := fmt.Sprintf("%T", step)
+	return name[strings.LastIndex(name, ".")+1:]
+}
+
+func milliseconds(d time.Duration) int64 {
+	return int64(d / time.Millisecond)
+}
@@ -0,0 +1,74 @@
+package linode
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/packer/helper/multistep"
+)
+
+type testMetricsStep struct {
+	client *http.Client
+	url    string
+}
+
+func (s *testMetricsStep) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	resp, err := s.client.Get(s.url + "/linode/instances")
+	if err != nil {
+		return multistep.ActionHalt
+	}
+	resp.Body.Close()
+	return multistep.ActionContinue
+}
+
+func (s *testMetricsStep) Cleanup(state multistep.StateBag) {}
+
+func TestBuildMetrics(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	var log bytes.Buffer
+	metrics := newBuildMetrics(&log)
+	client := &http.Client{Transport: metrics.transport(nil)}
+
+	step := &timedStep{Step: &testMetricsStep{client, server.URL}, metrics: metrics}
+	state := new(multistep.BasicStateBag)
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad: %#v", action)
+	}
+	step.Cleanup(state)
+
+	summary := metrics.summary()
+	if summary.APICalls != 1 || len(summary.Steps) != 2 {
+		t.Fatalf("bad: %#v", summary)
+	}
+	run := summary.Steps[0]
+	if run.Step != "testMetricsStep" || run.Phase != "run" || run.Action != "continue" || run.APICalls != 1 {
+		t.Errorf("bad: %#v", run)
+	}
+
+	var events []string
+	for _, line := range strings.Split(strings.TrimSpace(log.String()), "\n") {
+		var record struct {
+			Event string `json:"event"`
+			Path  string `json:"path"`
+			Step  string `json:"step"`
+		}
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			t.Fatalf("invalid JSON line %q: %s", line, err)
+		}
+		if record.Step != "testMetricsStep" {
+			t.Errorf("record should be attributed to the step: %s", line)
+		}
+		events = append(events, record.Event)
+	}
+	expected := "step_start,api_call,step_end,step_start,step_end"
+	if strings.Join(events, ",") != expected {
+		t.Errorf("found %v, expected %s", events, expected)
+	}
+}
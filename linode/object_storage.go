@@ -0,0 +1,145 @@
+package linode
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/hashicorp/packer/packer"
+)
+
+// ObjectStorageConfig locates a bucket of Linode Object Storage, or of another
+// S3-compatible service when Endpoint is set.
+type ObjectStorageConfig struct {
+	Cluster   string `mapstructure:"cluster"`
+	Endpoint  string `mapstructure:"endpoint"`
+	Bucket    string `mapstructure:"bucket"`
+	AccessKey string `mapstructure:"access_key"`
+	SecretKey string `mapstructure:"secret_key"`
+
+	// AccessKeyEnv and SecretKeyEnv name the environment variables the keys
+	// are read from when they are not set.
+	AccessKeyEnv string `mapstructure:"access_key_env"`
+	SecretKeyEnv string `mapstructure:"secret_key_env"`
+}
+
+// Empty reports whether no bucket is configured.
+func (o *ObjectStorageConfig) Empty() bool {
+	return *o == ObjectStorageConfig{}
+}
+
+// prepare sets the keys left empty from the environment and validates the
+// configuration of the option name.
+func (o *ObjectStorageConfig) prepare(name string) []error {
+	if o.AccessKeyEnv == "" {
+		o.AccessKeyEnv = "LINODE_OBJ_ACCESS_KEY"
+	}
+	if o.SecretKeyEnv == "" {
+		o.SecretKeyEnv = "LINODE_OBJ_SECRET_KEY"
+	}
+	if o.AccessKey == "" {
+		o.AccessKey = os.Getenv(o.AccessKeyEnv)
+	}
+	if o.SecretKey == "" {
+		o.SecretKey = os.Getenv(o.SecretKeyEnv)
+	}
+	packer.LogSecretFilter.Set(o.SecretKey)
+
+	var errs []error
+	if o.Cluster == "" && o.Endpoint == "" {
+		errs = append(errs, fmt.Errorf("%s: cluster or endpoint is required", name))
+	}
+	if o.Bucket == "" {
+		errs = append(errs, fmt.Errorf("%s: bucket is required", name))
+	}
+	if o.AccessKey == "" || o.SecretKey == "" {
+		errs = append(errs, fmt.Errorf("%s: access_key and secret_key are required, or %s and %s", name, o.AccessKeyEnv, o.SecretKeyEnv))
+	}
+	return errs
+}
+
+// endpoint returns the URL of the S3 API of the bucket.
+func (o *ObjectStorageConfig) endpoint() string {
+	if o.Endpoint != "" {
+		return o.Endpoint
+	}
+	return fmt.Sprintf("https://%s.linodeobjects.com", o.Cluster)
+}
+
+// url returns the location of the object key.
+func (o *ObjectStorageConfig) url(key string) string {
+	return fmt.Sprintf("s3://%s/%s", o.Bucket, key)
+}
+
+// session returns a session of the S3 API of the bucket.
+func (o *ObjectStorageConfig) session() (*session.Session, error) {
+	region := o.Cluster
+	if region == "" {
+		region = "us-east-1"
+	}
+	sess, err := session.NewSession(&aws.Config{
+		Credentials: credentials.NewStaticCredentials(o.AccessKey, o.SecretKey, ""),
+		Endpoint:    aws.String(o.endpoint()),
+		Region:      aws.String(region),
+		// S3-compatible services other than Linode Object Storage often
+		// don't support virtual hosted buckets.
+		S3ForcePathStyle: aws.Bool(o.Endpoint != ""),
+	})
+	if err != nil {
+		return nil, errors.New("unable to create the object storage session: " + err.Error())
+	}
+	return sess, nil
+}
+
+// presign returns URLs valid for ttl to download the objects of gets and
+// upload the objects of puts, by the names they are given.
+func (o *ObjectStorageConfig) presign(gets, puts map[string]string, ttl time.Duration) (map[string]string, error) {
+	sess, err := o.session()
+	if err != nil {
+		return nil, err
+	}
+	svc := s3.New(sess)
+
+	urls := make(map[string]string)
+	for name, key := range gets {
+		req, _ := svc.GetObjectRequest(&s3.GetObjectInput{
+			Bucket: aws.String(o.Bucket),
+			Key:    aws.String(key),
+		})
+		if urls[name], err = req.Presign(ttl); err != nil {
+			return nil, fmt.Errorf("unable to presign %s: %s", key, err)
+		}
+	}
+	for name, key := range puts {
+		req, _ := svc.PutObjectRequest(&s3.PutObjectInput{
+			Bucket: aws.String(o.Bucket),
+			Key:    aws.String(key),
+		})
+		if urls[name], err = req.Presign(ttl); err != nil {
+			return nil, fmt.Errorf("unable to presign %s: %s", key, err)
+		}
+	}
+	return urls, nil
+}
+
+// put uploads data to the object key.
+func (o *ObjectStorageConfig) put(ctx context.Context, key, contentType string, data []byte) error {
+	sess, err := o.session()
+	if err != nil {
+		return err
+	}
+	_, err = s3.New(sess).PutObjectWithContext(ctx, &s3.PutObjectInput{
+		Bucket:      aws.String(o.Bucket),
+		Key:         aws.String(key),
+		ContentType: aws.String(contentType),
+		Body:        bytes.NewReader(data),
+	})
+	return err
+}
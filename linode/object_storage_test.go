@@ -0,0 +1,90 @@
+package linode
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testObjectStorage returns a configuration of a local S3-compatible
+// stand-in, which records the objects put to it.
+func testObjectStorage(t *testing.T) (*ObjectStorageConfig, map[string]string, func()) {
+	objects := make(map[string]string)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "PUT" {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			t.Error(err)
+		}
+		objects[r.URL.Path] = string(body)
+	}))
+
+	o := &ObjectStorageConfig{
+		Endpoint:  server.URL,
+		Bucket:    "builds",
+		AccessKey: "access",
+		SecretKey: "secret",
+	}
+	return o, objects, server.Close
+}
+
+func TestObjectStoragePresign(t *testing.T) {
+	o, _, closeServer := testObjectStorage(t)
+	defer closeServer()
+
+	urls, err := o.presign(
+		map[string]string{"APP_URL": "inputs/app.tar.gz"},
+		map[string]string{"REPORT_URL": "outputs/report.txt"},
+		time.Hour)
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+
+	for name, path := range map[string]string{
+		"APP_URL":    "/builds/inputs/app.tar.gz",
+		"REPORT_URL": "/builds/outputs/report.txt",
+	} {
+		u, err := url.Parse(urls[name])
+		if err != nil {
+			t.Fatalf("bad URL %s: %s", urls[name], err)
+		}
+		if !strings.HasPrefix(urls[name], o.Endpoint) || u.Path != path {
+			t.Errorf("bad URL for %s: %s", name, urls[name])
+		}
+		if u.Query().Get("X-Amz-Signature") == "" || u.Query().Get("X-Amz-Expires") != "3600" {
+			t.Errorf("URL for %s is not presigned: %s", name, urls[name])
+		}
+	}
+}
+
+func TestObjectStoragePut(t *testing.T) {
+	o, objects, closeServer := testObjectStorage(t)
+	defer closeServer()
+
+	if err := o.put(context.Background(), "packer/build/build.log", "text/plain", []byte("log")); err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+	if log := objects["/builds/packer/build/build.log"]; log != "log" {
+		t.Errorf("bad object: %#v", objects)
+	}
+}
+
+func TestEnvFile(t *testing.T) {
+	env := envFile(map[string]string{
+		"REPORT_URL": "https://example.com/report?a=1&b='2'",
+		"APP_URL":    "https://example.com/app",
+	})
+	expected := "export APP_URL='https://example.com/app'\n" +
+		"export REPORT_URL='https://example.com/report?a=1&b='\"'\"'2'\"'\"''\n"
+	if env != expected {
+		t.Fatalf("found %q, expected %q", env, expected)
+	}
+}
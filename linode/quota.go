@@ -0,0 +1,70 @@
+package linode
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/linode/linodego"
+)
+
+// estimateCost returns the cost of running an instance of instanceType for
+// duration. Instances are billed by the started hour.
+func estimateCost(instanceType *linodego.LinodeType, duration time.Duration) float64 {
+	if instanceType.Price == nil {
+		return 0
+	}
+	hours := math.Ceil(duration.Hours())
+	if hours < 1 {
+		hours = 1
+	}
+	return float64(instanceType.Price.Hourly) * hours
+}
+
+// checkImageQuota returns the configured image limits the new image would
+// exceed. The size of the new image isn't known before it is captured, so it is
+// estimated from the size of the source image, when it was looked up.
+func checkImageQuota(c *Config, images []linodego.Image, source *linodego.Image) []string {
+	var problems []string
+
+	count, used := 0, 0
+	for _, image := range images {
+		if image.IsPublic {
+			continue
+		}
+		used += image.Size
+		if image.Type != "automatic" {
+			count++
+		}
+	}
+
+	if c.ImageCountLimit > 0 && count >= c.ImageCountLimit {
+		problems = append(problems, fmt.Sprintf(
+			"the account has %d images, the new image would exceed the limit of %d", count, c.ImageCountLimit))
+	}
+
+	estimate := 0
+	if source != nil {
+		estimate = source.Size
+	}
+
+	if c.ImageSizeLimit > 0 && estimate > c.ImageSizeLimit {
+		problems = append(problems, fmt.Sprintf(
+			"the new image is estimated at %d MB from the size of its source image, over the limit of %d MB per image",
+			estimate, c.ImageSizeLimit))
+	}
+
+	if c.ImageStorageLimit > 0 {
+		switch {
+		case used >= c.ImageStorageLimit:
+			problems = append(problems, fmt.Sprintf(
+				"the images of the account use %d MB, the new image would exceed the limit of %d MB", used, c.ImageStorageLimit))
+		case used+estimate > c.ImageStorageLimit:
+			problems = append(problems, fmt.Sprintf(
+				"the images of the account use %d MB, the new image, estimated at %d MB from the size of its source image, would exceed the limit of %d MB",
+				used, estimate, c.ImageStorageLimit))
+		}
+	}
+
+	return problems
+}
@@ -0,0 +1,54 @@
+package linode
+
+import (
+	"testing"
+	"time"
+
+	"github.com/linode/linodego"
+)
+
+func TestEstimateCost(t *testing.T) {
+	instanceType := &linodego.LinodeType{Price: &linodego.LinodePrice{Hourly: 0.5}}
+
+	cases := map[time.Duration]float64{
+		10 * time.Minute: 0.5,
+		time.Hour:        0.5,
+		90 * time.Minute: 1,
+	}
+	for duration, expected := range cases {
+		if cost := estimateCost(instanceType, duration); cost != expected {
+			t.Errorf("%s: found %v, expected %v", duration, cost, expected)
+		}
+	}
+}
+
+func TestCheckImageQuota(t *testing.T) {
+	c := &Config{ImageCountLimit: 2, ImageSizeLimit: 6144, ImageStorageLimit: 10000}
+	images := []linodego.Image{
+		{ID: "private/1", Type: "manual", Size: 2000},
+		{ID: "private/2", Type: "automatic", Size: 2000},
+	}
+	source := &linodego.Image{ID: "private/4", Size: 1500}
+
+	if problems := checkImageQuota(c, images, source); len(problems) > 0 {
+		t.Fatalf("bad: %#v", problems)
+	}
+
+	images = append(images, linodego.Image{ID: "private/3", Type: "manual", Size: 2000})
+	source.Size = 8000
+	if problems := checkImageQuota(c, images, source); len(problems) != 3 {
+		t.Fatalf("count, size and storage should exceed the limits: %#v", problems)
+	}
+
+	// Without a source image only the storage already used is known.
+	if problems := checkImageQuota(&Config{ImageStorageLimit: 10000}, images, nil); len(problems) > 0 {
+		t.Fatalf("bad: %#v", problems)
+	}
+	if problems := checkImageQuota(&Config{ImageStorageLimit: 6000}, images, nil); len(problems) != 1 {
+		t.Fatalf("storage should exceed the limit: %#v", problems)
+	}
+
+	if problems := checkImageQuota(&Config{}, images, source); len(problems) > 0 {
+		t.Fatalf("limits should be opt-in: %#v", problems)
+	}
+}
@@ -0,0 +1,157 @@
+package linode
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/packer/packer"
+	"github.com/linode/linodego"
+	"golang.org/x/crypto/ssh"
+)
+
+// rescueSSHReady is printed once SSH has been started in the rescue
+// environment. The command computes it so that the echo of the command itself
+// doesn't match.
+const (
+	rescueSSHReady        = "packer-rescue-ssh-42"
+	rescueSSHReadyCommand = "echo packer-rescue-ssh-$((6*7))"
+)
+
+// rescueDiskDevice is where the disk of the instance is attached in the
+// rescue environment.
+const rescueDiskDevice = "/dev/sda"
+
+// enableRescueSSH starts the SSH server of the Finnix rescue environment
+// through the Lish console, which is logged in as root, and authorizes
+// publicKey. No password is set, as anything typed into the console ends up in
+// its scrollback.
+func enableRescueSSH(ctx context.Context, conn *ssh.Client, instance *linodego.Instance, publicKey string) error {
+	session, err := conn.NewSession()
+	if err != nil {
+		return err
+	}
+	defer session.Close()
+
+	if err := session.RequestPty("vt100", 40, 200, ssh.TerminalModes{}); err != nil {
+		return err
+	}
+	stdin, err := session.StdinPipe()
+	if err != nil {
+		return err
+	}
+	stdout, err := session.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := session.Start(instance.Label); err != nil {
+		return err
+	}
+	console := &consoleReader{r: stdout}
+
+	// Keep pressing enter until the shell prompt shows up, as the rescue
+	// environment may still be booting.
+	stop, stopped := make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(5 * time.Second)
+		defer ticker.Stop()
+		for {
+			if _, err := io.WriteString(stdin, "\r"); err != nil {
+				return
+			}
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	err = console.expect("root@")
+	close(stop)
+	<-stopped
+	if err != nil {
+		return consoleError(ctx, err)
+	}
+
+	commands := []string{
+		"mkdir -p /root/.ssh",
+		"chmod 700 /root/.ssh",
+		fmt.Sprintf("echo %s > /root/.ssh/authorized_keys", shellQuote(strings.TrimSpace(publicKey))),
+		"(service ssh start || /etc/init.d/ssh start)",
+		rescueSSHReadyCommand,
+	}
+	if _, err := io.WriteString(stdin, strings.Join(commands, " && ")+"\r"); err != nil {
+		return err
+	}
+	if err := console.expect(rescueSSHReady); err != nil {
+		return consoleError(ctx, err)
+	}
+	return nil
+}
+
+func consoleError(ctx context.Context, err error) error {
+	if ctx.Err() != nil {
+		return fmt.Errorf("timeout waiting for the rescue environment: %s", ctx.Err())
+	}
+	return fmt.Errorf("unable to read the console: %s", err)
+}
+
+// consoleReader reads the output of an interactive console.
+type consoleReader struct {
+	r    io.Reader
+	seen []byte
+}
+
+// expect reads until s is found in the output.
+func (c *consoleReader) expect(s string) error {
+	buf := make([]byte, 4096)
+	for {
+		if i := bytes.Index(c.seen, []byte(s)); i >= 0 {
+			c.seen = c.seen[i+len(s):]
+			return nil
+		}
+		n, err := c.r.Read(buf)
+		c.seen = append(c.seen, buf[:n]...)
+		if err != nil && !bytes.Contains(c.seen, []byte(s)) {
+			return err
+		}
+	}
+}
+
+// chrootCommunicator runs commands in, and transfers files to and from, a
+// chroot on the instance.
+type chrootCommunicator struct {
+	packer.Communicator
+	root string
+}
+
+func (c *chrootCommunicator) Start(cmd *packer.RemoteCmd) error {
+	cmd.Command = fmt.Sprintf("chroot %s /bin/sh -c %s", shellQuote(c.root), shellQuote(cmd.Command))
+	return c.Communicator.Start(cmd)
+}
+
+func (c *chrootCommunicator) Upload(dst string, r io.Reader, fi *os.FileInfo) error {
+	return c.Communicator.Upload(c.path(dst), r, fi)
+}
+
+func (c *chrootCommunicator) UploadDir(dst string, src string, exclude []string) error {
+	return c.Communicator.UploadDir(c.path(dst), src, exclude)
+}
+
+func (c *chrootCommunicator) Download(src string, w io.Writer) error {
+	return c.Communicator.Download(c.path(src), w)
+}
+
+func (c *chrootCommunicator) DownloadDir(src string, dst string, exclude []string) error {
+	return c.Communicator.DownloadDir(c.path(src), dst, exclude)
+}
+
+func (c *chrootCommunicator) path(p string) string {
+	return path.Join(c.root, p)
+}
@@ -0,0 +1,87 @@
+package linode
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/packer/packer"
+	"golang.org/x/crypto/ssh"
+)
+
+func TestConsoleReaderExpect(t *testing.T) {
+	console := &consoleReader{r: strings.NewReader(
+		"Booting...\r\nroot@finnix:~# echo packer-rescue-ssh-$((6*7))\r\npacker-rescue-ssh-42\r\n")}
+
+	if err := console.expect("root@"); err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+	if err := console.expect(rescueSSHReady); err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+	if err := console.expect("root@"); err == nil {
+		t.Fatal("should have error")
+	}
+}
+
+func TestChrootCommunicator(t *testing.T) {
+	mock := new(packer.MockCommunicator)
+	comm := &chrootCommunicator{Communicator: mock, root: "/mnt/packer"}
+
+	cmd := &packer.RemoteCmd{Command: "echo 'hello'"}
+	if err := comm.Start(cmd); err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+	expected := `chroot '/mnt/packer' /bin/sh -c 'echo '"'"'hello'"'"''`
+	if mock.StartCmd.Command != expected {
+		t.Errorf("bad: %s", mock.StartCmd.Command)
+	}
+
+	if err := comm.Upload("/tmp/script.sh", bytes.NewReader(nil), nil); err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+	if mock.UploadPath != "/mnt/packer/tmp/script.sh" {
+		t.Errorf("bad: %s", mock.UploadPath)
+	}
+}
+
+func TestRescuePublicKey(t *testing.T) {
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+	der, err := x509.MarshalECPrivateKey(priv)
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+	pub, err := ssh.NewPublicKey(&priv.PublicKey)
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+
+	c := new(Config)
+	c.Comm.SSHPrivateKey = pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der})
+	key, err := rescuePublicKey(c)
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+	if key != string(ssh.MarshalAuthorizedKey(pub)) {
+		t.Fatalf("bad: %s", key)
+	}
+
+	c.Comm.SSHPublicKey = []byte("ssh-rsa AAAA")
+	if key, _ := rescuePublicKey(c); key != "ssh-rsa AAAA" {
+		t.Fatalf("the temporary key should be used: %s", key)
+	}
+
+	c = new(Config)
+	c.Comm.SSHPrivateKey = []byte("not a key")
+	if _, err := rescuePublicKey(c); err == nil {
+		t.Fatal("should have error")
+	}
+}
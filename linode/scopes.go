@@ -0,0 +1,216 @@
+package linode
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/linode/linodego"
+)
+
+// scopeLevels orders the access levels of OAuth scopes.
+var scopeLevels = map[string]int{
+	"read_only":  1,
+	"read_write": 2,
+}
+
+// requiredScopes returns the OAuth scopes the build needs, mapped to the
+// access level it needs. The builder neither manages Cloud Firewalls, as
+// keep_instance_on_failure firewalls the instance with iptables, nor waits on
+// events, so those are not needed.
+func requiredScopes(c *Config) map[string]string {
+	scopes := map[string]string{
+		"linodes": "read_write",
+		"images":  "read_write",
+	}
+	if c.SSHHostKeySeed {
+		scopes["stackscripts"] = "read_write"
+	}
+	if len(c.Volumes) > 0 {
+		scopes["volumes"] = "read_write"
+	}
+	return scopes
+}
+
+// requiredGrants returns the global grants a restricted user needs for the
+// build. Attaching an existing volume needs a grant on the volume itself,
+// which isn't checked.
+func requiredGrants(c *Config) []string {
+	grants := []string{"add_linodes", "add_images"}
+	if c.SSHHostKeySeed {
+		grants = append(grants, "add_stackscripts")
+	}
+	for _, v := range c.Volumes {
+		if v.ID == 0 {
+			grants = append(grants, "add_volumes")
+			break
+		}
+	}
+	return grants
+}
+
+// missingScopes returns the required scopes not granted by scopes, a comma or
+// space separated list of OAuth scopes such as "linodes:read_write".
+func missingScopes(scopes string, required map[string]string) []string {
+	granted := make(map[string]int)
+	for _, scope := range strings.FieldsFunc(scopes, func(r rune) bool { return r == ',' || r == ' ' }) {
+		if scope == "*" {
+			return nil
+		}
+		parts := strings.SplitN(scope, ":", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		if level := scopeLevels[parts[1]]; level > granted[parts[0]] {
+			granted[parts[0]] = level
+		}
+	}
+
+	var missing []string
+	for name, level := range required {
+		if granted[name] < scopeLevels[level] {
+			missing = append(missing, name+":"+level)
+		}
+	}
+	sort.Strings(missing)
+	return missing
+}
+
+// scopeProbes are the endpoints creating the resources each scope is needed
+// for.
+var scopeProbes = map[string]string{
+	"linodes":      "/linode/instances",
+	"images":       "/images",
+	"stackscripts": "/linode/stackscripts",
+	"volumes":      "/volumes",
+}
+
+// probeScopes finds out which of the required scopes the token is missing by
+// posting an empty object to the endpoints that need them. The API rejects a
+// token without the scope as unauthorized before it validates the request,
+// and rejects the request itself as invalid otherwise, so that nothing is
+// created. Scopes the answer says nothing about are returned as unverified.
+func probeScopes(ctx context.Context, c *Config, httpClient *http.Client, required map[string]string) (missing, unverified []string) {
+	var names []string
+	for name := range required {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		scope := name + ":" + required[name]
+		path, ok := scopeProbes[name]
+		if !ok {
+			unverified = append(unverified, scope)
+			continue
+		}
+
+		req, err := http.NewRequest("POST", c.apiBaseURL()+path, strings.NewReader("{}"))
+		if err != nil {
+			unverified = append(unverified, scope)
+			continue
+		}
+		req = req.WithContext(ctx)
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("User-Agent", userAgent())
+
+		resp, err := httpClient.Do(req)
+		if err != nil {
+			log.Printf("Unable to probe the %s scope: %s", scope, err)
+			unverified = append(unverified, scope)
+			continue
+		}
+		resp.Body.Close()
+
+		// Forbidden requests are left to the check of the grants.
+		switch resp.StatusCode {
+		case http.StatusBadRequest, http.StatusForbidden:
+		case http.StatusUnauthorized:
+			missing = append(missing, scope)
+		default:
+			log.Printf("Unexpected status probing the %s scope: %d", scope, resp.StatusCode)
+			unverified = append(unverified, scope)
+		}
+	}
+	return missing, unverified
+}
+
+type profileToken struct {
+	Token  string `json:"token"`
+	Scopes string `json:"scopes"`
+}
+
+type profileGrants struct {
+	Global map[string]interface{} `json:"global"`
+}
+
+// checkPermissions verifies that the token and the user it belongs to are
+// allowed to do everything the build needs. Permissions that can't be looked
+// up are returned as warnings.
+func checkPermissions(ctx context.Context, c *Config, httpClient *http.Client) ([]string, error) {
+	var warnings, problems []string
+
+	// Tokens restricted to the scopes of the build can't list tokens, their
+	// scopes are then found out by probing the endpoints the build uses.
+	var missing []string
+	scopes, err := tokenScopes(ctx, c, httpClient)
+	if err != nil {
+		log.Printf("Unable to look up the scopes of the token, probing them: %s", err)
+		var unverified []string
+		missing, unverified = probeScopes(ctx, c, httpClient, requiredScopes(c))
+		if len(unverified) > 0 {
+			warnings = append(warnings, "the scopes "+strings.Join(unverified, ", ")+" of the token were not verified")
+		}
+	} else {
+		missing = missingScopes(scopes, requiredScopes(c))
+	}
+	if len(missing) > 0 {
+		problems = append(problems, "the token is missing the scopes "+strings.Join(missing, ", "))
+	}
+
+	// Unrestricted users have no grants to look up.
+	var grants profileGrants
+	if err := getJSON(ctx, httpClient, c.apiBaseURL(), "/profile/grants", &grants); err != nil {
+		warnings = append(warnings, fmt.Sprintf("unable to look up the grants of the user: %s", err))
+	} else if grants.Global != nil {
+		var missing []string
+		for _, grant := range requiredGrants(c) {
+			if allowed, _ := grants.Global[grant].(bool); !allowed {
+				missing = append(missing, grant)
+			}
+		}
+		if len(missing) > 0 {
+			problems = append(problems, "the user is missing the grants "+strings.Join(missing, ", "))
+		}
+	}
+
+	if len(problems) > 0 {
+		return warnings, fmt.Errorf("insufficient permissions: %s", strings.Join(problems, "; "))
+	}
+	return warnings, nil
+}
+
+// tokenScopes returns the scopes of the configured token. The API only
+// returns the beginning of tokens, which is enough to tell them apart.
+func tokenScopes(ctx context.Context, c *Config, httpClient *http.Client) (string, error) {
+	var tokens struct {
+		Data []profileToken `json:"data"`
+	}
+	if err := getJSON(ctx, httpClient, c.apiBaseURL(), "/profile/tokens?page_size=500", &tokens); err != nil {
+		if apiErr, ok := err.(*linodego.Error); ok && (apiErr.Code == http.StatusUnauthorized || apiErr.Code == http.StatusForbidden) {
+			return "", fmt.Errorf("the token can't list tokens")
+		}
+		return "", err
+	}
+
+	for _, t := range tokens.Data {
+		prefix := strings.TrimRight(t.Token, ".")
+		if len(prefix) >= 8 && strings.HasPrefix(c.PersonalAccessToken, prefix) {
+			return t.Scopes, nil
+		}
+	}
+	return "", fmt.Errorf("the token was not found among the tokens of the profile")
+}
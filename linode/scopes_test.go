@@ -0,0 +1,105 @@
+package linode
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestMissingScopes(t *testing.T) {
+	required := requiredScopes(&Config{SSHHostKeySeed: true})
+
+	cases := map[string][]string{
+		"*": nil,
+		"linodes:read_write,images:read_write,stackscripts:read_write": nil,
+		"linodes:read_write images:read_only":                          {"images:read_write", "stackscripts:read_write"},
+		"account:read_only":                                            {"images:read_write", "linodes:read_write", "stackscripts:read_write"},
+	}
+	for scopes, expected := range cases {
+		if missing := missingScopes(scopes, required); !reflect.DeepEqual(missing, expected) {
+			t.Errorf("%s: found %v, expected %v", scopes, missing, expected)
+		}
+	}
+}
+
+func TestRequiredScopes_volumes(t *testing.T) {
+	c := &Config{Volumes: []VolumeConfig{{ID: 123}}}
+	if level := requiredScopes(c)["volumes"]; level != "read_write" {
+		t.Fatalf("volumes should need volumes:read_write: %q", level)
+	}
+	if grants := requiredGrants(c); !reflect.DeepEqual(grants, []string{"add_linodes", "add_images"}) {
+		t.Fatalf("existing volumes should not need add_volumes: %#v", grants)
+	}
+
+	c.Volumes = append(c.Volumes, VolumeConfig{Size: 10})
+	if grants := requiredGrants(c); !reflect.DeepEqual(grants, []string{"add_linodes", "add_images", "add_volumes"}) {
+		t.Fatalf("temporary volumes should need add_volumes: %#v", grants)
+	}
+
+	if _, ok := requiredScopes(&Config{})["volumes"]; ok {
+		t.Fatal("volumes should not be needed without volumes")
+	}
+}
+
+func TestCheckPermissions(t *testing.T) {
+	grants := ""
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v4/linode/instances":
+			w.WriteHeader(http.StatusBadRequest)
+		case "/v4/images":
+			if r.Method != "POST" {
+				t.Errorf("bad method: %s", r.Method)
+			}
+			w.WriteHeader(http.StatusUnauthorized)
+		case "/v4/profile/tokens":
+			w.Write([]byte(`{"data": [
+				{"token": "zyxwvutsrqponmlk", "scopes": "*"},
+				{"token": "abcdefghijklmnop", "scopes": "linodes:read_write,images:read_only"}
+			]}`))
+		case "/v4/profile/grants":
+			if grants == "" {
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+			w.Write([]byte(grants))
+		default:
+			t.Errorf("bad path: %s", r.URL.Path)
+		}
+	}))
+	defer server.Close()
+
+	c := &Config{
+		PersonalAccessToken: "abcdefghijklmnopqrstuvwxyz",
+		APIURL:              server.URL,
+		APIVersion:          "v4",
+	}
+	warnings, err := checkPermissions(context.Background(), c, http.DefaultClient)
+	if len(warnings) > 0 {
+		t.Fatalf("bad: %#v", warnings)
+	}
+	if err == nil || !strings.Contains(err.Error(), "images:read_write") {
+		t.Fatalf("missing scopes should be listed: %v", err)
+	}
+
+	c.PersonalAccessToken = "zyxwvutsrqponmlkjihgfedcba"
+	grants = `{"global": {"add_linodes": true, "add_images": false}}`
+	_, err = checkPermissions(context.Background(), c, http.DefaultClient)
+	if err == nil || !strings.Contains(err.Error(), "add_images") || strings.Contains(err.Error(), "add_linodes") {
+		t.Fatalf("missing grants should be listed: %v", err)
+	}
+
+	// The scopes of tokens that can't be looked up are probed.
+	c.PersonalAccessToken = "0123456789abcdefghijklmnop"
+	grants = ""
+	warnings, err = checkPermissions(context.Background(), c, http.DefaultClient)
+	if len(warnings) > 0 {
+		t.Fatalf("bad: %#v", warnings)
+	}
+	if err == nil || !strings.Contains(err.Error(), "images:read_write") || strings.Contains(err.Error(), "linodes") {
+		t.Fatalf("missing scopes should be probed: %v", err)
+	}
+}
@@ -1,8 +1,12 @@
 package linode
 
 import (
+	"bytes"
 	"fmt"
+	"log"
+	"net"
 
+	"github.com/hashicorp/packer/helper/communicator"
 	"github.com/hashicorp/packer/helper/multistep"
 	"github.com/linode/linodego"
 	"golang.org/x/crypto/ssh"
@@ -16,12 +20,39 @@ func commHost(state multistep.StateBag) (string, error) {
 	return instance.IPv4[0].String(), nil
 }
 
-func sshConfig(state multistep.StateBag) (*ssh.ClientConfig, error) {
-	return &ssh.ClientConfig{
-		User:            "root",
-		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
-		Auth: []ssh.AuthMethod{
-			ssh.Password(state.Get("root_pass").(string)),
-		},
-	}, nil
+// sshConfig wraps the communicator's SSH configuration so that the host key
+// is verified. A key seeded into the instance is expected from the first
+// connection, otherwise the first key presented is trusted and pinned for the
+// rest of the build.
+func sshConfig(comm *communicator.Config) func(multistep.StateBag) (*ssh.ClientConfig, error) {
+	sshConfigFunc := comm.SSHConfigFunc()
+
+	return func(state multistep.StateBag) (*ssh.ClientConfig, error) {
+		config, err := sshConfigFunc(state)
+		if err != nil {
+			return nil, err
+		}
+
+		if rawKey, ok := state.GetOk("ssh_host_key"); ok {
+			config.HostKeyAlgorithms = []string{rawKey.(ssh.PublicKey).Type()}
+		}
+
+		config.HostKeyCallback = func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+			rawKey, ok := state.GetOk("ssh_host_key")
+			if !ok {
+				log.Printf("Pinning SSH host key of %s: %s", hostname, ssh.FingerprintSHA256(key))
+				state.Put("ssh_host_key", key)
+				return nil
+			}
+
+			expected := rawKey.(ssh.PublicKey)
+			if !bytes.Equal(expected.Marshal(), key.Marshal()) {
+				return fmt.Errorf("SSH host key of %s has changed: expected %s, got %s",
+					hostname, ssh.FingerprintSHA256(expected), ssh.FingerprintSHA256(key))
+			}
+			return nil
+		}
+
+		return config, nil
+	}
 }
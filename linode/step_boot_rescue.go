@@ -0,0 +1,97 @@
+package linode
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/hashicorp/packer/helper/multistep"
+	"github.com/hashicorp/packer/packer"
+	"github.com/linode/linodego"
+	"golang.org/x/crypto/ssh"
+)
+
+// stepBootRescue boots the instance into the Finnix rescue environment with
+// its disk attached, and starts SSH in it through Lish so that the
+// communicator can connect.
+type stepBootRescue struct {
+	client linodego.Client
+}
+
+func (s *stepBootRescue) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	c := state.Get("config").(*Config)
+	ui := state.Get("ui").(packer.Ui)
+	instance := state.Get("instance").(*linodego.Instance)
+	disk := state.Get("disk").(*linodego.InstanceDisk)
+
+	// The rescue environment has a host key of its own, the one of the
+	// instance is kept for the artifact.
+	if hostKey, ok := state.GetOk("ssh_host_key"); ok {
+		state.Put("instance_ssh_host_key", hostKey)
+		state.Remove("ssh_host_key")
+	}
+
+	ui.Say("Booting Linode into rescue mode...")
+	err := s.client.RescueInstance(ctx, instance.ID, linodego.RescueInstanceOptions{
+		Devices: linodego.InstanceConfigDeviceMap{
+			SDA: &linodego.InstanceConfigDevice{DiskID: disk.ID},
+		},
+	})
+	if err != nil {
+		err = errors.New("Error booting Linode into rescue mode: " + err.Error())
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	instance, err = s.client.WaitForInstanceStatus(ctx, instance.ID, linodego.InstanceRunning, int(c.stateTimeout.Seconds()))
+	if err != nil {
+		err = errors.New("Error booting Linode into rescue mode: " + err.Error())
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+	state.Put("instance", instance)
+
+	ui.Say("Enabling SSH in the rescue environment...")
+	if err := s.enableSSH(ctx, c, instance); err != nil {
+		err = errors.New("Error enabling SSH in the rescue environment: " + err.Error())
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	return multistep.ActionContinue
+}
+
+func (s *stepBootRescue) enableSSH(ctx context.Context, c *Config, instance *linodego.Instance) error {
+	ctx, cancel := context.WithTimeout(ctx, c.stateTimeout)
+	defer cancel()
+
+	conn, err := dialLish(ctx, s.client, c.LishUsername, c.LishSSHKeyFile, instance.Region)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	publicKey, err := rescuePublicKey(c)
+	if err != nil {
+		return err
+	}
+	return enableRescueSSH(ctx, conn, instance, publicKey)
+}
+
+// rescuePublicKey returns the public key to authorize in the rescue
+// environment: the temporary key, or the one of ssh_private_key_file.
+func rescuePublicKey(c *Config) (string, error) {
+	if len(c.Comm.SSHPublicKey) > 0 {
+		return string(c.Comm.SSHPublicKey), nil
+	}
+	signer, err := ssh.ParsePrivateKey(c.Comm.SSHPrivateKey)
+	if err != nil {
+		return "", fmt.Errorf("unable to read the public key of ssh_private_key_file, which must not be encrypted: %s", err)
+	}
+	return string(ssh.MarshalAuthorizedKey(signer.PublicKey())), nil
+}
+
+func (s *stepBootRescue) Cleanup(state multistep.StateBag) {}
@@ -0,0 +1,71 @@
+package linode
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"time"
+
+	"github.com/hashicorp/packer/helper/multistep"
+	"github.com/hashicorp/packer/packer"
+	"github.com/linode/linodego"
+)
+
+// stepCaptureConsole saves the instance's serial console output when a later
+// step fails, such as the communicator failing to connect, or when the build
+// is interrupted. It runs before the communicator connects so that its cleanup
+// runs once the steps after it are cleaned up, before the instance is
+// deleted.
+type stepCaptureConsole struct {
+	Source consoleSource
+	Path   string
+	Lines  int
+}
+
+func (s *stepCaptureConsole) Run(_ context.Context, state multistep.StateBag) multistep.StepAction {
+	return multistep.ActionContinue
+}
+
+func (s *stepCaptureConsole) Cleanup(state multistep.StateBag) {
+	if buildFailed(state) {
+		s.capture(state)
+	}
+}
+
+func (s *stepCaptureConsole) capture(state multistep.StateBag) {
+	rawInstance, ok := state.GetOk("instance")
+	if !ok {
+		return
+	}
+
+	ui := state.Get("ui").(packer.Ui)
+	instance := rawInstance.(*linodego.Instance)
+
+	ui.Say("Capturing serial console output...")
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	out, err := s.Source.ConsoleLog(ctx, instance)
+	if err != nil {
+		ui.Error("Error capturing serial console output: " + err.Error())
+		return
+	}
+
+	if err := ioutil.WriteFile(s.Path, out, 0644); err != nil {
+		ui.Error("Error saving serial console output: " + err.Error())
+		return
+	}
+
+	ui.Message(fmt.Sprintf("Console output saved to %s, last %d lines:", s.Path, s.Lines))
+	for _, line := range tailLines(out, s.Lines) {
+		ui.Message(line)
+	}
+}
+
+// buildFailed reports whether a step has failed or the build was interrupted.
+func buildFailed(state multistep.StateBag) bool {
+	_, errored := state.GetOk("error")
+	_, cancelled := state.GetOk(multistep.StateCancelled)
+	_, halted := state.GetOk(multistep.StateHalted)
+	return errored || cancelled || halted
+}
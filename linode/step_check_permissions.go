@@ -0,0 +1,37 @@
+package linode
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/hashicorp/packer/helper/multistep"
+	"github.com/hashicorp/packer/packer"
+)
+
+// stepCheckPermissions fails the build before anything is created if the
+// token is missing scopes, or the user grants, needed for the build.
+type stepCheckPermissions struct {
+	httpClient *http.Client
+}
+
+func (s *stepCheckPermissions) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	c := state.Get("config").(*Config)
+	ui := state.Get("ui").(packer.Ui)
+
+	ui.Say("Checking token permissions...")
+	warnings, err := checkPermissions(ctx, c, s.httpClient)
+	for _, warning := range warnings {
+		ui.Message("Warning: " + warning)
+	}
+	if err != nil {
+		err = fmt.Errorf("Error checking token permissions: %s", err)
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	return multistep.ActionContinue
+}
+
+func (s *stepCheckPermissions) Cleanup(state multistep.StateBag) {}
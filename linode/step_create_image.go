@@ -19,14 +19,38 @@ func (s *stepCreateImage) Run(ctx context.Context, state multistep.StateBag) mul
 	disk := state.Get("disk").(*linodego.InstanceDisk)
 	instance := state.Get("instance").(*linodego.Instance)
 
+	var parent *linodego.Image
+	if sourceImage, ok := state.GetOk("source_image"); ok {
+		parent = sourceImage.(*linodego.Image)
+	}
+
+	info := newBuildInfo(c, parent)
+	label, err := renderImageLabel(c, info)
+	if err != nil {
+		err = errors.New("Error rendering image label: " + err.Error())
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+	description, err := renderImageDescription(c, info, parent)
+	if err != nil {
+		err = errors.New("Error rendering image description: " + err.Error())
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
 	ui.Say("Creating image...")
 	image, err := s.client.CreateImage(ctx, linodego.ImageCreateOptions{
 		DiskID:      disk.ID,
-		Label:       c.ImageLabel,
-		Description: c.Description,
+		Label:       label,
+		Description: description,
 	})
 
+	// The image is put in the state as soon as it exists, so that it is
+	// deleted if the build fails or is cancelled from here on.
 	if err == nil {
+		state.Put("image", image)
 		_, err = s.client.WaitForInstanceDiskStatus(ctx, instance.ID, disk.ID, linodego.DiskReady, 600)
 	}
 
@@ -45,4 +69,17 @@ func (s *stepCreateImage) Run(ctx context.Context, state multistep.StateBag) mul
 	return multistep.ActionContinue
 }
 
-func (s *stepCreateImage) Cleanup(state multistep.StateBag) {}
+// Cleanup deletes the image if a later step, such as the export, fails, as
+// no artifact is returned for it.
+func (s *stepCreateImage) Cleanup(state multistep.StateBag) {
+	image, ok := state.GetOk("image")
+	if !ok || !buildFailed(state) {
+		return
+	}
+
+	ui := state.Get("ui").(packer.Ui)
+	ui.Say("Deleting image of the failed build...")
+	if err := s.client.DeleteImage(context.Background(), image.(*linodego.Image).ID); err != nil && !isNotFound(err) {
+		ui.Error("Error cleaning up image: " + err.Error())
+	}
+}
@@ -21,7 +21,7 @@ func (s *stepCreateLinode) Run(ctx context.Context, state multistep.StateBag) mu
 	ui.Say("Creating Linode...")
 
 	createOpts := linodego.InstanceCreateOptions{
-		RootPass:       c.Comm.Password(),
+		RootPass:       c.RootPass,
 		AuthorizedKeys: []string{string(c.Comm.SSHPublicKey)},
 		Region:         c.Region,
 		Type:           c.InstanceType,
@@ -30,6 +30,18 @@ func (s *stepCreateLinode) Run(ctx context.Context, state multistep.StateBag) mu
 		SwapSize:       &c.SwapSize,
 	}
 
+	if stackscript, ok := state.GetOk("stackscript"); ok {
+		createOpts.StackScriptID = stackscript.(*linodego.Stackscript).ID
+		createOpts.StackScriptData = state.Get("stackscript_data").(map[string]string)
+	}
+
+	// The configuration profile can only be changed and volumes attached
+	// once the instance exists, so it is booted once that is done.
+	if c.customBoot() || c.BootMode == "rescue" || len(c.Volumes) > 0 {
+		booted := false
+		createOpts.Booted = &booted
+	}
+
 	instance, err := s.client.CreateInstance(ctx, createOpts)
 	if err != nil {
 		err = errors.New("Error creating Linode: " + err.Error())
@@ -39,8 +51,43 @@ func (s *stepCreateLinode) Run(ctx context.Context, state multistep.StateBag) mu
 	}
 	state.Put("instance", instance)
 
-	// wait until instance is running
-	for instance.Status != linodego.InstanceRunning {
+	if len(c.Volumes) > 0 {
+		ui.Say("Attaching volumes...")
+		volumes, err := attachVolumes(ctx, s.client, c, instance)
+		state.Put("volumes", volumes)
+		if err != nil {
+			err = errors.New("Error attaching volumes: " + err.Error())
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+	}
+
+	if c.customBoot() {
+		ui.Say("Configuring Linode boot...")
+		if err := configureBoot(ctx, s.client, c, instance.ID); err != nil {
+			err = errors.New("Error configuring Linode boot: " + err.Error())
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+	} else if len(c.Volumes) > 0 {
+		ui.Say("Booting Linode...")
+		if err := s.client.BootInstance(ctx, instance.ID, 0); err != nil {
+			err = errors.New("Error booting Linode: " + err.Error())
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+	}
+
+	// wait until instance is running, or offline if it is to be booted into
+	// rescue mode
+	status := linodego.InstanceRunning
+	if c.BootMode == "rescue" {
+		status = linodego.InstanceOffline
+	}
+	for instance.Status != status {
 		time.Sleep(2 * time.Second)
 		if instance, err = s.client.GetInstance(ctx, instance.ID); err != nil {
 			err = errors.New("Error creating Linode: " + err.Error())
@@ -51,7 +98,7 @@ func (s *stepCreateLinode) Run(ctx context.Context, state multistep.StateBag) mu
 		state.Put("instance", instance)
 	}
 
-	disk, err := s.findDisk(ctx, instance.ID)
+	disk, err := findDisk(ctx, s.client, instance.ID)
 	if err != nil {
 		err = errors.New("Error creating Linode: " + err.Error())
 		state.Put("error", err)
@@ -67,8 +114,9 @@ func (s *stepCreateLinode) Run(ctx context.Context, state multistep.StateBag) mu
 	return multistep.ActionContinue
 }
 
-func (s *stepCreateLinode) findDisk(ctx context.Context, instanceID int) (*linodego.InstanceDisk, error) {
-	disks, err := s.client.ListInstanceDisks(ctx, instanceID, nil)
+// findDisk returns the disk of the instance the image is captured from.
+func findDisk(ctx context.Context, client linodego.Client, instanceID int) (*linodego.InstanceDisk, error) {
+	disks, err := client.ListInstanceDisks(ctx, instanceID, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -86,9 +134,22 @@ func (s *stepCreateLinode) Cleanup(state multistep.StateBag) {
 		return
 	}
 
+	c := state.Get("config").(*Config)
 	ui := state.Get("ui").(packer.Ui)
 
+	// A cancelled build is not kept, the user asked for it to stop.
+	_, cancelled := state.GetOk(multistep.StateCancelled)
+	if c.KeepInstanceOnFailure && buildFailed(state) && !cancelled {
+		if !keepInstance(state, instance.(*linodego.Instance)) {
+			return
+		}
+	}
+
 	if err := s.client.DeleteInstance(context.Background(), instance.(*linodego.Instance).ID); err != nil {
 		ui.Error("Error cleaning up Linode: " + err.Error())
 	}
+
+	if volumes, ok := state.GetOk("volumes"); ok {
+		deleteTemporaryVolumes(context.Background(), s.client, c, ui, volumes.([]*buildVolume))
+	}
 }
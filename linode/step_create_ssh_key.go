@@ -16,9 +16,13 @@ import (
 )
 
 // StepCreateSSHKey represents a Packer build step that generates SSH key pairs.
+// The key is saved to DebugKeyPath in debug mode, or when the instance may be
+// kept after a failed build, in which case it is removed again unless the
+// instance was kept.
 type StepCreateSSHKey struct {
-	Debug        bool
-	DebugKeyPath string
+	Debug         bool
+	DebugKeyPath  string
+	KeepOnFailure bool
 }
 
 // Run executes the Packer build step that generates SSH key pairs.
@@ -38,6 +42,7 @@ func (s *StepCreateSSHKey) Run(_ context.Context, state multistep.StateBag) mult
 
 		config.Comm.SSHPrivateKey = privateKeyBytes
 		config.Comm.SSHPublicKey = nil
+		state.Put("ssh_private_key_path", config.Comm.SSHPrivateKeyFile)
 
 		return multistep.ActionContinue
 	}
@@ -72,9 +77,11 @@ func (s *StepCreateSSHKey) Run(_ context.Context, state multistep.StateBag) mult
 		config.Comm.SSHPublicKey = config.Comm.SSHPublicKey[:len(config.Comm.SSHPublicKey)-1]
 	}
 
-	if s.Debug {
-		ui.Message(fmt.Sprintf("Saving key for debug purposes: %s", s.DebugKeyPath))
-		f, err := os.Create(s.DebugKeyPath)
+	if s.Debug || s.KeepOnFailure {
+		if s.Debug {
+			ui.Message(fmt.Sprintf("Saving key for debug purposes: %s", s.DebugKeyPath))
+		}
+		f, err := os.OpenFile(s.DebugKeyPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
 		if err != nil {
 			state.Put("error", fmt.Errorf("Error saving debug key: %s", err))
 			return multistep.ActionHalt
@@ -87,9 +94,24 @@ func (s *StepCreateSSHKey) Run(_ context.Context, state multistep.StateBag) mult
 			state.Put("error", fmt.Errorf("Error saving debug key: %s", err))
 			return multistep.ActionHalt
 		}
+		state.Put("ssh_private_key_path", s.DebugKeyPath)
 	}
 	return multistep.ActionContinue
 }
 
-// Nothing to clean up. SSH keys are associated with a single Linode instance.
-func (s *StepCreateSSHKey) Cleanup(state multistep.StateBag) {}
+// The key is only removed if it was saved for an instance that wasn't kept.
+// SSH keys are otherwise associated with a single Linode instance.
+func (s *StepCreateSSHKey) Cleanup(state multistep.StateBag) {
+	if s.Debug || !s.KeepOnFailure {
+		return
+	}
+	if _, ok := state.GetOk("instance_kept"); ok {
+		return
+	}
+	if path, ok := state.GetOk("ssh_private_key_path"); ok && path.(string) == s.DebugKeyPath {
+		if err := os.Remove(s.DebugKeyPath); err != nil && !os.IsNotExist(err) {
+			ui := state.Get("ui").(packer.Ui)
+			ui.Error(fmt.Sprintf("Error removing temporary SSH key: %s", err))
+		}
+	}
+}
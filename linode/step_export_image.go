@@ -0,0 +1,105 @@
+package linode
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/hashicorp/packer/helper/multistep"
+	"github.com/hashicorp/packer/packer"
+	"github.com/linode/linodego"
+)
+
+// stepExportImage copies the disk the image was captured from to export_path
+// and uploads it to export_object_storage, from the rescue environment.
+type stepExportImage struct{}
+
+func (s *stepExportImage) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	c := state.Get("config").(*Config)
+	ui := state.Get("ui").(packer.Ui)
+	comm := state.Get("communicator").(packer.Communicator)
+	disk := state.Get("disk").(*linodego.InstanceDisk)
+	image := state.Get("image").(*linodego.Image)
+
+	path := c.ExportPath
+	if path == "" {
+		f, err := ioutil.TempFile("", "packer-linode-export-")
+		if err != nil {
+			err = errors.New("Error creating export file: " + err.Error())
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+		f.Close()
+		path = f.Name()
+	}
+	state.Put("export_path", path)
+
+	ui.Say(fmt.Sprintf("Exporting %d MiB disk to %s...", disk.Size, path))
+	f, err := os.Create(path)
+	if err == nil {
+		err = exportDisk(ctx, comm, ui, f, disk.Size, c.ExportChunkSize, c.ExportRetries)
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	var sum string
+	if err == nil {
+		sum, err = fileChecksum(path)
+	}
+	if err != nil {
+		err = errors.New("Error exporting image: " + err.Error())
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+	state.Put("export_sha256", sum)
+
+	if c.ExportPath != "" {
+		checksumPath, err := writeChecksumFile(path, sum)
+		if err != nil {
+			err = errors.New("Error writing export checksum: " + err.Error())
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+		state.Put("export_files", []string{path, checksumPath})
+	}
+
+	if !c.ExportObjectStorage.Empty() {
+		key := c.ExportObjectKey
+		if key == "" && c.target != "" {
+			key = image.Label + "-" + c.target + ".img.gz"
+		} else if key == "" {
+			key = image.Label + ".img.gz"
+		}
+		url := c.ExportObjectStorage.url(key)
+
+		ui.Say(fmt.Sprintf("Uploading exported image to %s...", url))
+		if err := uploadExport(ctx, &c.ExportObjectStorage, key, path, sum, c.ExportRetries); err != nil {
+			err = errors.New("Error uploading exported image: " + err.Error())
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+		state.Put("export_url", url)
+	}
+
+	return multistep.ActionContinue
+}
+
+// Cleanup removes the file the image was exported to if it is only used for
+// the upload, or if the export failed.
+func (s *stepExportImage) Cleanup(state multistep.StateBag) {
+	c := state.Get("config").(*Config)
+	path, ok := state.GetOk("export_path")
+	if !ok {
+		return
+	}
+	if _, exported := state.GetOk("export_files"); exported && c.ExportPath != "" {
+		return
+	}
+	os.Remove(path.(string))
+}
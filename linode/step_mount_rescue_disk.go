@@ -0,0 +1,64 @@
+package linode
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/hashicorp/packer/helper/multistep"
+	"github.com/hashicorp/packer/packer"
+)
+
+// stepMountRescueDisk mounts the disk of the instance in the rescue
+// environment and makes the communicator run in a chroot of it, so that
+// provisioners act on the disk being captured.
+type stepMountRescueDisk struct{}
+
+func (s *stepMountRescueDisk) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	c := state.Get("config").(*Config)
+	ui := state.Get("ui").(packer.Ui)
+	comm := state.Get("communicator").(packer.Communicator)
+
+	ui.Say(fmt.Sprintf("Mounting %s at %s...", rescueDiskDevice, c.RescueMountPath))
+	root := shellQuote(c.RescueMountPath)
+	command := fmt.Sprintf("mkdir -p %s && mount %s %s && for d in dev proc sys; do mount --bind /$d %s/$d; done",
+		root, rescueDiskDevice, root, root)
+	if err := runRemoteCommand(comm, ui, command); err != nil {
+		err = errors.New("Error mounting the disk: " + err.Error())
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	state.Put("rescue_communicator", comm)
+	state.Put("communicator", &chrootCommunicator{Communicator: comm, root: c.RescueMountPath})
+	return multistep.ActionContinue
+}
+
+func (s *stepMountRescueDisk) Cleanup(state multistep.StateBag) {}
+
+// stepUnmountRescueDisk unmounts the disk mounted by stepMountRescueDisk so
+// that it is consistent when it is captured, and restores the communicator of
+// the rescue environment.
+type stepUnmountRescueDisk struct{}
+
+func (s *stepUnmountRescueDisk) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	c := state.Get("config").(*Config)
+	ui := state.Get("ui").(packer.Ui)
+	comm := state.Get("rescue_communicator").(packer.Communicator)
+	state.Put("communicator", comm)
+
+	ui.Say(fmt.Sprintf("Unmounting %s...", c.RescueMountPath))
+	root := shellQuote(c.RescueMountPath)
+	command := fmt.Sprintf("sync && umount %s/sys %s/proc %s/dev %s", root, root, root, root)
+	if err := runRemoteCommand(comm, ui, command); err != nil {
+		err = errors.New("Error unmounting the disk: " + err.Error())
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	return multistep.ActionContinue
+}
+
+func (s *stepUnmountRescueDisk) Cleanup(state multistep.StateBag) {}
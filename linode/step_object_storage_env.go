@@ -0,0 +1,77 @@
+package linode
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/packer/helper/multistep"
+	"github.com/hashicorp/packer/packer"
+)
+
+// stepObjectStorageEnv writes presigned URLs of the objects of
+// object_storage_urls and object_storage_upload_urls to an environment file
+// on the instance, for the provisioners to source. The URLs are presigned
+// just before provisioning so that they last as long as possible.
+type stepObjectStorageEnv struct{}
+
+func (s *stepObjectStorageEnv) Run(_ context.Context, state multistep.StateBag) multistep.StepAction {
+	c := state.Get("config").(*Config)
+	ui := state.Get("ui").(packer.Ui)
+	comm := state.Get("communicator").(packer.Communicator)
+
+	ui.Say("Presigning object storage URLs...")
+	urls, err := c.ObjectStorage.presign(c.ObjectStorageURLs, c.ObjectStorageUploadURLs, c.objectStorageURLTTL)
+	if err == nil {
+		err = comm.Upload(c.ObjectStorageEnvFile, strings.NewReader(envFile(urls)), nil)
+	}
+	if err != nil {
+		err = errors.New("Error writing object storage URLs: " + err.Error())
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+	ui.Message(fmt.Sprintf("Provisioners can source %s", c.ObjectStorageEnvFile))
+
+	return multistep.ActionContinue
+}
+
+func (s *stepObjectStorageEnv) Cleanup(state multistep.StateBag) {}
+
+// stepRemoveObjectStorageEnv removes the file written by stepObjectStorageEnv
+// so that the image doesn't carry the presigned URLs.
+type stepRemoveObjectStorageEnv struct{}
+
+func (s *stepRemoveObjectStorageEnv) Run(_ context.Context, state multistep.StateBag) multistep.StepAction {
+	c := state.Get("config").(*Config)
+	ui := state.Get("ui").(packer.Ui)
+	comm := state.Get("communicator").(packer.Communicator)
+
+	if err := runRemoteCommand(comm, ui, "rm -f "+shellQuote(c.ObjectStorageEnvFile)); err != nil {
+		err = errors.New("Error removing object storage URLs: " + err.Error())
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	return multistep.ActionContinue
+}
+
+func (s *stepRemoveObjectStorageEnv) Cleanup(state multistep.StateBag) {}
+
+// envFile returns a shell script exporting vars, sorted by name.
+func envFile(vars map[string]string) string {
+	names := make([]string, 0, len(vars))
+	for name := range vars {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	for _, name := range names {
+		fmt.Fprintf(&b, "export %s=%s\n", name, shellQuote(vars[name]))
+	}
+	return b.String()
+}
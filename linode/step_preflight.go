@@ -0,0 +1,83 @@
+package linode
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/packer/helper/multistep"
+	"github.com/hashicorp/packer/packer"
+	"github.com/linode/linodego"
+)
+
+// stepPreflight estimates the cost of the build and checks the image limits
+// of the account before the instance is created.
+type stepPreflight struct {
+	client linodego.Client
+}
+
+func (s *stepPreflight) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	c := state.Get("config").(*Config)
+	ui := state.Get("ui").(packer.Ui)
+
+	// The plan is recorded so that the artifact tells which class of plan the
+	// image was built on.
+	instanceType, err := s.client.GetType(ctx, c.InstanceType)
+	if err != nil {
+		err = errors.New("Error looking up instance type: " + err.Error())
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+	state.Put("instance_type", instanceType)
+
+	// Capturing an existing instance doesn't create one.
+	if c.SourceInstanceID == 0 || c.SourceInstanceClone {
+		cost := estimateCost(instanceType, c.expectedBuildDuration)
+		ui.Say(fmt.Sprintf("Estimated build cost: $%.4f (%s for %s)", cost, c.InstanceType, c.expectedBuildDuration))
+		if c.MaxBuildCost > 0 && cost > c.MaxBuildCost {
+			err := fmt.Errorf("Error: the estimated build cost of $%.4f exceeds max_build_cost ($%.4f)", cost, c.MaxBuildCost)
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+	}
+
+	if !c.checkImageQuota() {
+		return multistep.ActionContinue
+	}
+
+	ui.Say("Checking image limits...")
+	rawFilter, _ := json.Marshal(map[string]interface{}{"is_public": false})
+	images, err := s.client.ListImages(ctx, linodego.NewListOptions(0, string(rawFilter)))
+	if err != nil {
+		err = errors.New("Error listing images: " + err.Error())
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	var source *linodego.Image
+	if rawSource, ok := state.GetOk("source_image"); ok {
+		source = rawSource.(*linodego.Image)
+	}
+	problems := checkImageQuota(c, images, source)
+	if len(problems) == 0 {
+		return multistep.ActionContinue
+	}
+
+	if c.ImageQuotaCheck == "fail" {
+		err := errors.New("Error checking image limits: " + strings.Join(problems, "; "))
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+	for _, problem := range problems {
+		ui.Message("Warning: " + problem)
+	}
+	return multistep.ActionContinue
+}
+
+func (s *stepPreflight) Cleanup(state multistep.StateBag) {}
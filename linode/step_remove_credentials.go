@@ -0,0 +1,41 @@
+package linode
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/hashicorp/packer/helper/multistep"
+	"github.com/hashicorp/packer/packer"
+)
+
+// stepRemoveCredentials locks the root password and removes the temporary SSH
+// key from the instance so that the image doesn't carry the credentials used
+// during the build. The current connection stays usable, but new ones can't be
+// made after this step.
+type stepRemoveCredentials struct{}
+
+func (s *stepRemoveCredentials) Run(_ context.Context, state multistep.StateBag) multistep.StepAction {
+	c := state.Get("config").(*Config)
+	ui := state.Get("ui").(packer.Ui)
+	comm := state.Get("communicator").(packer.Communicator)
+
+	ui.Say("Removing build credentials from instance...")
+	command := "passwd -l root"
+	if len(c.Comm.SSHPublicKey) > 0 {
+		command += fmt.Sprintf(
+			" && { grep -vF '%s' /root/.ssh/authorized_keys > /root/.ssh/authorized_keys.packer"+
+				"; mv /root/.ssh/authorized_keys.packer /root/.ssh/authorized_keys; }",
+			c.Comm.SSHPublicKey)
+	}
+
+	if err := runRemoteCommand(comm, ui, command); err != nil {
+		err = fmt.Errorf("Error removing build credentials: %s", err)
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	return multistep.ActionContinue
+}
+
+func (s *stepRemoveCredentials) Cleanup(state multistep.StateBag) {}
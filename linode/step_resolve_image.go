@@ -0,0 +1,118 @@
+package linode
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/packer/helper/multistep"
+	"github.com/hashicorp/packer/packer"
+	"github.com/linode/linodego"
+)
+
+// stepResolveImage looks up the source image, resolving image_filter,
+// source_image_from_manifest or source_build_tag to an image ID which is then
+// used as if it had been set as image.
+type stepResolveImage struct {
+	client linodego.Client
+}
+
+func (s *stepResolveImage) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	c := state.Get("config").(*Config)
+	ui := state.Get("ui").(packer.Ui)
+
+	ui.Say("Resolving source image...")
+	image, err := s.resolve(ctx, c)
+	if err != nil {
+		err = errors.New("Error resolving source image: " + err.Error())
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	ui.Message(fmt.Sprintf("Using source image: %s (%s)", image.Label, image.ID))
+	c.Image = image.ID
+	state.Put("source_image", image)
+
+	return multistep.ActionContinue
+}
+
+func (s *stepResolveImage) resolve(ctx context.Context, c *Config) (*linodego.Image, error) {
+	switch {
+	case !c.ImageFilter.Empty():
+		return s.resolveFilter(ctx, &c.ImageFilter)
+	case c.SourceImageFromManifest != "":
+		m, err := readManifest(c.SourceImageFromManifest)
+		if err != nil {
+			return nil, err
+		}
+		id, err := m.imageID(c.SourceManifestBuildName)
+		if err != nil {
+			return nil, err
+		}
+		if id, err = regionImageID(id, c.Region); err != nil {
+			return nil, err
+		}
+		return s.client.GetImage(ctx, id)
+	case c.SourceBuildTag != "":
+		return s.resolveBuildTag(ctx, c.SourceBuildTag)
+	default:
+		return s.client.GetImage(ctx, c.Image)
+	}
+}
+
+func (s *stepResolveImage) resolveFilter(ctx context.Context, filter *ImageFilterOptions) (*linodego.Image, error) {
+	images, err := s.client.ListImages(ctx, filter.listOptions())
+	if err != nil {
+		return nil, err
+	}
+
+	matches, err := filter.matchImages(images)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(matches) == 0 {
+		return nil, errors.New("no image matches image_filter")
+	}
+	if len(matches) > 1 && !filter.MostRecent {
+		var ids []string
+		for _, image := range matches {
+			ids = append(ids, image.ID)
+		}
+		return nil, fmt.Errorf("%d images match image_filter, set most_recent or narrow down the filter: %s",
+			len(matches), strings.Join(ids, ", "))
+	}
+
+	return &matches[0], nil
+}
+
+// resolveBuildTag returns the most recent private image built with the given
+// build_tag.
+func (s *stepResolveImage) resolveBuildTag(ctx context.Context, tag string) (*linodego.Image, error) {
+	rawFilter, _ := json.Marshal(map[string]interface{}{"is_public": false})
+	images, err := s.client.ListImages(ctx, linodego.NewListOptions(0, string(rawFilter)))
+	if err != nil {
+		return nil, err
+	}
+
+	var found *linodego.Image
+	for i := range images {
+		image := &images[i]
+		if image.IsPublic || lineageValue(image.Description, buildTagKey) != tag {
+			continue
+		}
+		if found == nil || (image.Created != nil && found.Created != nil && image.Created.After(*found.Created)) {
+			found = image
+		}
+	}
+
+	if found == nil {
+		return nil, fmt.Errorf("no image was built with build_tag %s", tag)
+	}
+	return found, nil
+}
+
+func (s *stepResolveImage) Cleanup(state multistep.StateBag) {}
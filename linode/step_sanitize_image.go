@@ -0,0 +1,116 @@
+package linode
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/hashicorp/packer/helper/multistep"
+	"github.com/hashicorp/packer/packer"
+)
+
+const sanitizeScriptPath = "/tmp/packer-sanitize.sh"
+
+// defaultSanitizeScript removes the identity of the build machine. Host keys
+// are regenerated on first boot, which Debian and Ubuntu need a drop-in for.
+const defaultSanitizeScript = `#!/bin/sh
+rm -f /etc/ssh/ssh_host_*
+if [ -f /etc/machine-id ]; then
+	: > /etc/machine-id
+fi
+rm -f /var/lib/dbus/machine-id
+
+if command -v cloud-init > /dev/null 2>&1; then
+	cloud-init clean --logs
+fi
+rm -rf /var/lib/cloud/instance /var/lib/cloud/instances
+
+rm -f /root/.bash_history /root/.ash_history /home/*/.bash_history /home/*/.ash_history
+
+if [ -n "$PACKER_TEMP_KEY" ] && [ -f /root/.ssh/authorized_keys ]; then
+	grep -vF "$PACKER_TEMP_KEY" /root/.ssh/authorized_keys > /root/.ssh/authorized_keys.packer
+	mv /root/.ssh/authorized_keys.packer /root/.ssh/authorized_keys
+fi
+
+[ -r /etc/os-release ] && . /etc/os-release
+case "$ID" in
+debian|ubuntu)
+	for unit in ssh sshd; do
+		mkdir -p /etc/systemd/system/$unit.service.d
+		printf '[Service]\nExecStartPre=-/usr/bin/ssh-keygen -A\n' \
+			> /etc/systemd/system/$unit.service.d/regenerate-host-keys.conf
+	done
+	apt-get clean
+	rm -rf /var/lib/apt/lists/*
+	rm -f /var/lib/dhcp/*.leases
+	;;
+centos|rhel|fedora)
+	if command -v dnf > /dev/null 2>&1; then
+		dnf clean all
+	else
+		yum clean all
+	fi
+	rm -f /var/lib/dhclient/*.lease*
+	;;
+opensuse*|sles)
+	zypper clean --all
+	;;
+arch)
+	pacman -Scc --noconfirm
+	;;
+alpine)
+	rm -rf /var/cache/apk/*
+	;;
+esac
+
+rm -rf /tmp/* /var/tmp/*
+`
+
+// stepSanitizeImage removes machine specific state such as host keys,
+// machine-id and shell history from the instance before it is captured.
+type stepSanitizeImage struct{}
+
+func (s *stepSanitizeImage) Run(_ context.Context, state multistep.StateBag) multistep.StepAction {
+	c := state.Get("config").(*Config)
+	ui := state.Get("ui").(packer.Ui)
+	comm := state.Get("communicator").(packer.Communicator)
+
+	ui.Say("Sanitizing image...")
+
+	script := []byte(defaultSanitizeScript)
+	if c.ImageSanitizeScript != "" {
+		var err error
+		if script, err = ioutil.ReadFile(c.ImageSanitizeScript); err != nil {
+			err = fmt.Errorf("Error reading sanitize script: %s", err)
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+	}
+
+	if err := comm.Upload(sanitizeScriptPath, bytes.NewReader(script), nil); err != nil {
+		err = fmt.Errorf("Error uploading sanitize script: %s", err)
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	command := fmt.Sprintf("PACKER_TEMP_KEY='%s' sh %s && rm -f %s",
+		c.Comm.SSHPublicKey, sanitizeScriptPath, sanitizeScriptPath)
+	if len(c.ImageSanitizePaths) > 0 {
+		command += fmt.Sprintf(" && rm -rf -- '%s'", strings.Join(c.ImageSanitizePaths, "' '"))
+	}
+
+	if err := runRemoteCommand(comm, ui, command); err != nil {
+		err = fmt.Errorf("Error sanitizing image: %s", err)
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	return multistep.ActionContinue
+}
+
+func (s *stepSanitizeImage) Cleanup(state multistep.StateBag) {}
@@ -0,0 +1,125 @@
+package linode
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+
+	"github.com/hashicorp/packer/helper/multistep"
+	"github.com/hashicorp/packer/packer"
+	"github.com/linode/linodego"
+	"golang.org/x/crypto/ssh"
+)
+
+// hostKeyStackScript installs the host key passed in as a UDF and restarts
+// sshd so that the first SSH connection can already be verified.
+const hostKeyStackScript = `#!/bin/sh
+# <UDF name="packer_host_key" label="Packer SSH host key" />
+umask 077
+echo "$PACKER_HOST_KEY" | base64 -d > /etc/ssh/ssh_host_ecdsa_key
+ssh-keygen -y -f /etc/ssh/ssh_host_ecdsa_key > /etc/ssh/ssh_host_ecdsa_key.pub
+chmod 644 /etc/ssh/ssh_host_ecdsa_key.pub
+systemctl restart ssh || systemctl restart sshd || service ssh restart || service sshd restart || rc-service sshd restart
+`
+
+// stepSeedHostKey generates an SSH host key for the instance and a temporary
+// StackScript that installs it when the instance first boots.
+type stepSeedHostKey struct {
+	client linodego.Client
+}
+
+func (s *stepSeedHostKey) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	c := state.Get("config").(*Config)
+	ui := state.Get("ui").(packer.Ui)
+
+	ui.Say("Creating SSH host key for instance...")
+	pub, privPEM, err := createHostKey()
+	if err != nil {
+		err = fmt.Errorf("Error creating SSH host key: %s", err)
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+	state.Put("ssh_host_key", pub)
+	state.Put("stackscript_data", map[string]string{
+		"packer_host_key": base64.StdEncoding.EncodeToString(privPEM),
+	})
+
+	stackscript, err := s.client.CreateStackscript(ctx, linodego.StackscriptCreateOptions{
+		Label:       c.Label + "-host-key",
+		Description: "Temporary StackScript installing the SSH host key of a Packer build",
+		Images:      []string{c.Image},
+		Script:      hostKeyStackScript,
+	})
+	if err != nil {
+		err = errors.New("Error creating StackScript: " + err.Error())
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+	state.Put("stackscript", stackscript)
+
+	return multistep.ActionContinue
+}
+
+// createHostKey generates an ECDSA host key, returning the public key and the
+// PEM encoded private key.
+func createHostKey() (ssh.PublicKey, []byte, error) {
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	der, err := x509.MarshalECPrivateKey(priv)
+	if err != nil {
+		return nil, nil, err
+	}
+	pub, err := ssh.NewPublicKey(&priv.PublicKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	return pub, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der}), nil
+}
+
+func (s *stepSeedHostKey) Cleanup(state multistep.StateBag) {
+	stackscript, ok := state.GetOk("stackscript")
+	if !ok {
+		return
+	}
+
+	ui := state.Get("ui").(packer.Ui)
+
+	if err := s.client.DeleteStackscript(context.Background(), stackscript.(*linodego.Stackscript).ID); err != nil {
+		ui.Error("Error cleaning up StackScript: " + err.Error())
+		return
+	}
+	state.Remove("stackscript")
+}
+
+// stepRemoveSeededHostKey removes the host key installed by stepSeedHostKey so
+// that instances created from the image don't share a key whose private half
+// was known to the build. It is only needed when the image isn't sanitized,
+// since sanitizing removes all host keys.
+type stepRemoveSeededHostKey struct{}
+
+func (s *stepRemoveSeededHostKey) Run(_ context.Context, state multistep.StateBag) multistep.StepAction {
+	ui := state.Get("ui").(packer.Ui)
+	comm := state.Get("communicator").(packer.Communicator)
+
+	ui.Say("Removing seeded SSH host key from instance...")
+	if err := runRemoteCommand(comm, ui, "rm -f /etc/ssh/ssh_host_ecdsa_key /etc/ssh/ssh_host_ecdsa_key.pub"); err != nil {
+		err = fmt.Errorf("Error removing seeded SSH host key: %s", err)
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	return multistep.ActionContinue
+}
+
+func (s *stepRemoveSeededHostKey) Cleanup(state multistep.StateBag) {}
@@ -3,6 +3,7 @@ package linode
 import (
 	"context"
 	"errors"
+	"fmt"
 
 	"github.com/hashicorp/packer/helper/multistep"
 	"github.com/hashicorp/packer/packer"
@@ -14,9 +15,18 @@ type stepShutdownLinode struct {
 }
 
 func (s *stepShutdownLinode) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	c := state.Get("config").(*Config)
 	ui := state.Get("ui").(packer.Ui)
 	instance := state.Get("instance").(*linodego.Instance)
 
+	if c.ShutdownCommand != "" {
+		err := s.shutdownGuest(ctx, state)
+		if err == nil {
+			return multistep.ActionContinue
+		}
+		ui.Message(fmt.Sprintf("Graceful shutdown failed, shutting down through the API: %s", err))
+	}
+
 	ui.Say("Shutting down Linode...")
 	if err := s.client.ShutdownInstance(ctx, instance.ID); err != nil {
 		err = errors.New("Error shutting down Linode: " + err.Error())
@@ -36,4 +46,41 @@ func (s *stepShutdownLinode) Run(ctx context.Context, state multistep.StateBag)
 	return multistep.ActionContinue
 }
 
+// shutdownGuest runs the shutdown command and waits for the instance to power
+// itself off. The Lassie watchdog is disabled meanwhile, since it would boot
+// the instance back up.
+func (s *stepShutdownLinode) shutdownGuest(ctx context.Context, state multistep.StateBag) error {
+	c := state.Get("config").(*Config)
+	ui := state.Get("ui").(packer.Ui)
+	comm := state.Get("communicator").(packer.Communicator)
+	instance := state.Get("instance").(*linodego.Instance)
+
+	ui.Say("Gracefully shutting down Linode...")
+	if instance.WatchdogEnabled {
+		watchdog := false
+		if _, err := s.client.UpdateInstance(ctx, instance.ID, linodego.InstanceUpdateOptions{
+			WatchdogEnabled: &watchdog,
+		}); err != nil {
+			return err
+		}
+		defer func() {
+			watchdog := true
+			if _, err := s.client.UpdateInstance(ctx, instance.ID, linodego.InstanceUpdateOptions{
+				WatchdogEnabled: &watchdog,
+			}); err != nil {
+				ui.Error("Error enabling the Linode watchdog: " + err.Error())
+			}
+		}()
+	}
+
+	// The command is not waited on, as the connection goes away with the
+	// instance.
+	if err := comm.Start(&packer.RemoteCmd{Command: c.ShutdownCommand}); err != nil {
+		return err
+	}
+
+	_, err := s.client.WaitForInstanceStatus(ctx, instance.ID, linodego.InstanceOffline, int(c.shutdownTimeout.Seconds()))
+	return err
+}
+
 func (s *stepShutdownLinode) Cleanup(state multistep.StateBag) {}
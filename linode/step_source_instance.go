@@ -0,0 +1,155 @@
+package linode
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/hashicorp/packer/helper/multistep"
+	"github.com/hashicorp/packer/packer"
+	"github.com/linode/linodego"
+)
+
+// stepResolveSourceInstance looks up source_instance_id. The region and
+// instance type of the build are those of the instance, unless they are set
+// for its clone.
+type stepResolveSourceInstance struct {
+	client linodego.Client
+}
+
+func (s *stepResolveSourceInstance) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	c := state.Get("config").(*Config)
+	ui := state.Get("ui").(packer.Ui)
+
+	ui.Say("Resolving source Linode...")
+	instance, err := s.client.GetInstance(ctx, c.SourceInstanceID)
+	if err != nil {
+		err = errors.New("Error resolving source Linode: " + err.Error())
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	ui.Message(fmt.Sprintf("Using source Linode: %s (%d)", instance.Label, instance.ID))
+	if c.Region == "" {
+		c.Region = instance.Region
+	}
+	if c.InstanceType == "" {
+		c.InstanceType = instance.Type
+	}
+	state.Put("source_instance", instance)
+
+	return multistep.ActionContinue
+}
+
+func (s *stepResolveSourceInstance) Cleanup(state multistep.StateBag) {}
+
+// stepSourceInstance takes the place of stepCreateLinode when an existing
+// instance is captured. The instance is cloned if source_instance_clone is
+// set, otherwise it is used as is and is never deleted.
+type stepSourceInstance struct {
+	client linodego.Client
+}
+
+func (s *stepSourceInstance) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	c := state.Get("config").(*Config)
+	ui := state.Get("ui").(packer.Ui)
+	source := state.Get("source_instance").(*linodego.Instance)
+	timeout := int(c.stateTimeout.Seconds())
+
+	instance := source
+	if c.SourceInstanceClone {
+		ui.Say(fmt.Sprintf("Cloning Linode %d...", source.ID))
+		clone, err := s.client.CloneInstance(ctx, source.ID, linodego.InstanceCloneOptions{
+			Region: c.Region,
+			Type:   c.InstanceType,
+			Label:  c.Label,
+		})
+		if err != nil {
+			err = errors.New("Error cloning Linode: " + err.Error())
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+		state.Put("instance", clone)
+
+		if clone, err = s.client.WaitForInstanceStatus(ctx, clone.ID, linodego.InstanceOffline, timeout); err != nil {
+			err = errors.New("Error cloning Linode: " + err.Error())
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+		instance = clone
+	} else {
+		state.Put("instance", instance)
+	}
+
+	if instance.Status != linodego.InstanceRunning {
+		ui.Say(fmt.Sprintf("Booting Linode %d...", instance.ID))
+		err := s.client.BootInstance(ctx, instance.ID, 0)
+		if err == nil {
+			instance, err = s.client.WaitForInstanceStatus(ctx, instance.ID, linodego.InstanceRunning, timeout)
+		}
+		if err != nil {
+			err = errors.New("Error booting Linode: " + err.Error())
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+		state.Put("instance", instance)
+	}
+
+	disk, err := findDisk(ctx, s.client, instance.ID)
+	if err != nil {
+		err = errors.New("Error finding Linode disk: " + err.Error())
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	} else if disk == nil {
+		err := errors.New("Error finding Linode disk: no suitable disk was found")
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+	state.Put("disk", disk)
+	return multistep.ActionContinue
+}
+
+// Cleanup deletes the clone, or boots the source instance again if it was
+// running before the build.
+func (s *stepSourceInstance) Cleanup(state multistep.StateBag) {
+	instance, ok := state.GetOk("instance")
+	if !ok {
+		return
+	}
+
+	c := state.Get("config").(*Config)
+	ui := state.Get("ui").(packer.Ui)
+	source := state.Get("source_instance").(*linodego.Instance)
+
+	if !c.SourceInstanceClone {
+		if source.Status != linodego.InstanceRunning {
+			return
+		}
+		current, err := s.client.GetInstance(context.Background(), source.ID)
+		if err == nil && current.Status == linodego.InstanceOffline {
+			ui.Say(fmt.Sprintf("Booting source Linode %d again...", source.ID))
+			err = s.client.BootInstance(context.Background(), source.ID, 0)
+		}
+		if err != nil {
+			ui.Error("Error booting source Linode: " + err.Error())
+		}
+		return
+	}
+
+	_, cancelled := state.GetOk(multistep.StateCancelled)
+	if c.KeepInstanceOnFailure && buildFailed(state) && !cancelled {
+		if !keepInstance(state, instance.(*linodego.Instance)) {
+			return
+		}
+	}
+
+	if err := s.client.DeleteInstance(context.Background(), instance.(*linodego.Instance).ID); err != nil {
+		ui.Error("Error cleaning up Linode: " + err.Error())
+	}
+}
@@ -0,0 +1,39 @@
+package linode
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/hashicorp/packer/helper/multistep"
+	"github.com/hashicorp/packer/packer"
+	"github.com/linode/linodego"
+)
+
+// stepValidateAPI checks that the region, instance type and image exist
+// before anything billable is created.
+type stepValidateAPI struct {
+	client     linodego.Client
+	httpClient *http.Client
+}
+
+func (s *stepValidateAPI) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	c := state.Get("config").(*Config)
+	ui := state.Get("ui").(packer.Ui)
+
+	ui.Say("Validating configuration against the Linode API...")
+	warnings, err := validateWithAPI(ctx, c, s.client, s.httpClient)
+	for _, warning := range warnings {
+		ui.Message("Warning: " + warning)
+	}
+	if err != nil {
+		err = fmt.Errorf("Error validating configuration: %s", err)
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	return multistep.ActionContinue
+}
+
+func (s *stepValidateAPI) Cleanup(state multistep.StateBag) {}
@@ -0,0 +1,78 @@
+package linode
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/hashicorp/packer/helper/multistep"
+	"github.com/hashicorp/packer/packer"
+	"github.com/linode/linodego"
+)
+
+// stepMountVolumes mounts the volumes that have a mount path.
+type stepMountVolumes struct{}
+
+func (s *stepMountVolumes) Run(_ context.Context, state multistep.StateBag) multistep.StepAction {
+	ui := state.Get("ui").(packer.Ui)
+	comm := state.Get("communicator").(packer.Communicator)
+	volumes := state.Get("volumes").([]*buildVolume)
+
+	for _, volume := range volumes {
+		if volume.config.MountPath == "" {
+			continue
+		}
+		ui.Say(fmt.Sprintf("Mounting volume %s at %s...", volume.Label, volume.config.MountPath))
+		if err := runRemoteCommand(comm, ui, mountCommand(volume)); err != nil {
+			err = fmt.Errorf("Error mounting volume %s: %s", volume.Label, err)
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+	}
+
+	return multistep.ActionContinue
+}
+
+func (s *stepMountVolumes) Cleanup(state multistep.StateBag) {}
+
+// stepDetachVolumes unmounts and detaches the volumes so that they are
+// neither part of the image nor referenced by it.
+type stepDetachVolumes struct {
+	client linodego.Client
+}
+
+func (s *stepDetachVolumes) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	c := state.Get("config").(*Config)
+	ui := state.Get("ui").(packer.Ui)
+	comm := state.Get("communicator").(packer.Communicator)
+	volumes := state.Get("volumes").([]*buildVolume)
+
+	for _, volume := range volumes {
+		if volume.config.MountPath != "" {
+			ui.Say(fmt.Sprintf("Unmounting volume %s...", volume.Label))
+			if err := runRemoteCommand(comm, ui, "sync && umount "+shellQuote(volume.config.MountPath)); err != nil {
+				err = fmt.Errorf("Error unmounting volume %s: %s", volume.Label, err)
+				state.Put("error", err)
+				ui.Error(err.Error())
+				return multistep.ActionHalt
+			}
+		}
+
+		ui.Say(fmt.Sprintf("Detaching volume %s...", volume.Label))
+		err := s.client.DetachVolume(ctx, volume.ID)
+		if err == nil {
+			_, err = s.client.WaitForVolumeLinodeID(ctx, volume.ID, nil, int(c.stateTimeout.Seconds()))
+		}
+		if err != nil {
+			err = errors.New("Error detaching volume: " + err.Error())
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+	}
+
+	return multistep.ActionContinue
+}
+
+func (s *stepDetachVolumes) Cleanup(state multistep.StateBag) {}
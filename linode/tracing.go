@@ -0,0 +1,372 @@
+package linode
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/packer/helper/multistep"
+	"github.com/hashicorp/packer/version"
+	"github.com/linode/linodego"
+)
+
+// OTLP span kinds and status codes.
+const (
+	spanKindInternal = 1
+	spanKindClient   = 3
+
+	spanStatusOk    = 1
+	spanStatusError = 2
+)
+
+const tracingScope = "packer-builder-linode"
+
+var (
+	traceparentRe  = regexp.MustCompile(`^[0-9a-f]{2}-([0-9a-f]{32})-([0-9a-f]{16})-[0-9a-f]{2}$`)
+	instancePathRe = regexp.MustCompile(`/linode/instances/(\d+)`)
+)
+
+// tracer records a trace of the build: a root span, a span per step and a
+// span per API request, which is exported to an OTLP/HTTP collector and/or a
+// file once the build is done. A nil tracer records nothing.
+type tracer struct {
+	mu          sync.Mutex
+	serviceName string
+	endpoint    string
+	file        string
+	traceID     string
+	parentID    string
+	root        *span
+	current     *span
+	spans       []*span
+}
+
+type span struct {
+	traceID    string
+	spanID     string
+	parentID   string
+	name       string
+	kind       int
+	start      time.Time
+	end        time.Time
+	attributes map[string]interface{}
+	status     int
+	message    string
+}
+
+// newTracer returns a tracer if tracing is configured. The trace continues
+// the one in the TRACEPARENT environment variable, if set, so that builds
+// show up in the trace of the CI job running them.
+func newTracer(c *Config) *tracer {
+	if c.TracingEndpoint == "" && c.TracingFile == "" {
+		return nil
+	}
+
+	t := &tracer{
+		serviceName: c.TracingServiceName,
+		endpoint:    c.TracingEndpoint,
+		file:        c.TracingFile,
+		traceID:     randomID(16),
+	}
+	if m := traceparentRe.FindStringSubmatch(os.Getenv("TRACEPARENT")); m != nil {
+		t.traceID, t.parentID = m[1], m[2]
+	}
+	return t
+}
+
+func randomID(n int) string {
+	id := make([]byte, n)
+	rand.Read(id)
+	return hex.EncodeToString(id)
+}
+
+// startBuild starts the root span of the trace.
+func (t *tracer) startBuild(c *Config) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.root = t.newSpan("packer build "+c.PackerBuildName, spanKindInternal, t.parentID)
+	t.root.attributes["packer.build.name"] = c.PackerBuildName
+	t.root.attributes["linode.region"] = c.Region
+	t.root.attributes["linode.instance.type"] = c.InstanceType
+}
+
+// endBuild ends the root span.
+func (t *tracer) endBuild(state multistep.StateBag) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	setStateAttributes(t.root, state)
+	if rawErr, ok := state.GetOk("error"); ok {
+		t.root.setError(rawErr.(error).Error())
+	} else if buildFailed(state) {
+		t.root.setError("build was cancelled or halted")
+	} else {
+		t.root.status = spanStatusOk
+	}
+	t.finish(t.root)
+}
+
+func (t *tracer) startStep(name, phase string) *span {
+	if t == nil {
+		return nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	s := t.newSpan(name, spanKindInternal, t.root.spanID)
+	s.attributes["packer.step.phase"] = phase
+	t.current = s
+	return s
+}
+
+func (t *tracer) endStep(s *span, state multistep.StateBag, halted bool) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	setStateAttributes(s, state)
+	if halted {
+		message := "step halted"
+		if rawErr, ok := state.GetOk("error"); ok {
+			message = rawErr.(error).Error()
+		}
+		s.setError(message)
+	}
+	if t.current == s {
+		t.current = nil
+	}
+	t.finish(s)
+}
+
+// transport wraps base so that API requests are traced as children of the
+// current step.
+func (t *tracer) transport(base http.RoundTripper) http.RoundTripper {
+	if t == nil {
+		return base
+	}
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &tracingTransport{base: base, tracer: t}
+}
+
+type tracingTransport struct {
+	base   http.RoundTripper
+	tracer *tracer
+}
+
+func (tt *tracingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t := tt.tracer
+
+	t.mu.Lock()
+	parent := t.current
+	if parent == nil {
+		parent = t.root
+	}
+	s := t.newSpan(req.Method+" "+req.URL.Path, spanKindClient, parent.spanID)
+	t.mu.Unlock()
+
+	s.attributes["http.method"] = req.Method
+	s.attributes["http.url"] = req.URL.String()
+	if m := instancePathRe.FindStringSubmatch(req.URL.Path); m != nil {
+		id, _ := strconv.Atoi(m[1])
+		s.attributes["linode.instance.id"] = id
+	}
+
+	resp, err := tt.base.RoundTrip(req)
+	if err != nil {
+		s.setError(err.Error())
+	} else {
+		s.attributes["http.status_code"] = resp.StatusCode
+		if resp.StatusCode >= 400 {
+			s.setError(http.StatusText(resp.StatusCode))
+		}
+	}
+
+	t.mu.Lock()
+	t.finish(s)
+	t.mu.Unlock()
+
+	return resp, err
+}
+
+// newSpan starts a span. t.mu must be held.
+func (t *tracer) newSpan(name string, kind int, parentID string) *span {
+	return &span{
+		traceID:    t.traceID,
+		spanID:     randomID(8),
+		parentID:   parentID,
+		name:       name,
+		kind:       kind,
+		start:      time.Now(),
+		attributes: make(map[string]interface{}),
+	}
+}
+
+// finish ends s. t.mu must be held.
+func (t *tracer) finish(s *span) {
+	s.end = time.Now()
+	t.spans = append(t.spans, s)
+}
+
+func (s *span) setError(message string) {
+	s.status = spanStatusError
+	s.message = message
+}
+
+// setStateAttributes describes the instance and images of the build on s.
+func setStateAttributes(s *span, state multistep.StateBag) {
+	if c, ok := state.GetOk("config"); ok {
+		s.attributes["linode.region"] = c.(*Config).Region
+		if image := c.(*Config).Image; image != "" {
+			s.attributes["linode.source_image.id"] = image
+		}
+	}
+	if instance, ok := state.GetOk("instance"); ok {
+		s.attributes["linode.instance.id"] = instance.(*linodego.Instance).ID
+	}
+	if image, ok := state.GetOk("image"); ok {
+		s.attributes["linode.image.id"] = image.(*linodego.Image).ID
+	}
+}
+
+// export sends the recorded spans to the collector and/or writes them to the
+// file, as an OTLP/JSON ExportTraceServiceRequest.
+func (t *tracer) export(ctx context.Context) error {
+	if t == nil {
+		return nil
+	}
+	t.mu.Lock()
+	data, err := json.Marshal(t.request())
+	t.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	if t.file != "" {
+		f, err := os.OpenFile(t.file, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+		if err != nil {
+			return err
+		}
+		_, err = f.Write(append(data, '\n'))
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	if t.endpoint != "" {
+		endpoint := t.endpoint
+		if !strings.HasSuffix(endpoint, "/v1/traces") {
+			endpoint = strings.TrimSuffix(endpoint, "/") + "/v1/traces"
+		}
+		req, err := http.NewRequest("POST", endpoint, bytes.NewReader(data))
+		if err != nil {
+			return err
+		}
+		req = req.WithContext(ctx)
+		req.Header.Set("Content-Type", "application/json")
+
+		client := &http.Client{Timeout: 10 * time.Second}
+		resp, err := client.Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode/100 != 2 {
+			body, _ := ioutil.ReadAll(resp.Body)
+			return fmt.Errorf("collector returned %s: %s", resp.Status, body)
+		}
+	}
+
+	return nil
+}
+
+// request builds the OTLP/JSON representation of the spans. t.mu must be
+// held.
+func (t *tracer) request() interface{} {
+	spans := make([]interface{}, 0, len(t.spans))
+	for _, s := range t.spans {
+		otlpSpan := map[string]interface{}{
+			"traceId":           s.traceID,
+			"spanId":            s.spanID,
+			"name":              s.name,
+			"kind":              s.kind,
+			"startTimeUnixNano": strconv.FormatInt(s.start.UnixNano(), 10),
+			"endTimeUnixNano":   strconv.FormatInt(s.end.UnixNano(), 10),
+			"attributes":        otlpAttributes(s.attributes),
+		}
+		if s.parentID != "" {
+			otlpSpan["parentSpanId"] = s.parentID
+		}
+		if s.status != 0 {
+			otlpSpan["status"] = map[string]interface{}{"code": s.status, "message": s.message}
+		}
+		spans = append(spans, otlpSpan)
+	}
+
+	return map[string]interface{}{
+		"resourceSpans": []interface{}{
+			map[string]interface{}{
+				"resource": map[string]interface{}{
+					"attributes": otlpAttributes(map[string]interface{}{
+						"service.name":    t.serviceName,
+						"service.version": version.FormattedVersion(),
+					}),
+				},
+				"scopeSpans": []interface{}{
+					map[string]interface{}{
+						"scope": map[string]interface{}{"name": tracingScope},
+						"spans": spans,
+					},
+				},
+			},
+		},
+	}
+}
+
+func otlpAttributes(attributes map[string]interface{}) []interface{} {
+	keys := make([]string, 0, len(attributes))
+	for k := range attributes {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	otlp := make([]interface{}, 0, len(keys))
+	for _, k := range keys {
+		var value map[string]interface{}
+		switch v := attributes[k].(type) {
+		case int:
+			value = map[string]interface{}{"intValue": strconv.Itoa(v)}
+		case bool:
+			value = map[string]interface{}{"boolValue": v}
+		default:
+			value = map[string]interface{}{"stringValue": fmt.Sprint(v)}
+		}
+		otlp = append(otlp, map[string]interface{}{"key": k, "value": value})
+	}
+	return otlp
+}
@@ -0,0 +1,131 @@
+package linode
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hashicorp/packer/helper/multistep"
+	"github.com/linode/linodego"
+)
+
+type otlpRequest struct {
+	ResourceSpans []struct {
+		ScopeSpans []struct {
+			Spans []struct {
+				TraceID      string `json:"traceId"`
+				SpanID       string `json:"spanId"`
+				ParentSpanID string `json:"parentSpanId"`
+				Name         string `json:"name"`
+				Attributes   []struct {
+					Key   string                 `json:"key"`
+					Value map[string]interface{} `json:"value"`
+				} `json:"attributes"`
+			} `json:"spans"`
+		} `json:"scopeSpans"`
+	} `json:"resourceSpans"`
+}
+
+func TestTracer(t *testing.T) {
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer api.Close()
+
+	var collected otlpRequest
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/traces" {
+			t.Errorf("bad path: %s", r.URL.Path)
+		}
+		if err := json.NewDecoder(r.Body).Decode(&collected); err != nil {
+			t.Errorf("invalid request: %s", err)
+		}
+	}))
+	defer collector.Close()
+
+	dir, err := ioutil.TempDir("", "packer")
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	c := &Config{
+		Region:             "us-east",
+		TracingEndpoint:    collector.URL,
+		TracingFile:        filepath.Join(dir, "trace.json"),
+		TracingServiceName: "packer",
+	}
+	tracer := newTracer(c)
+	tracer.startBuild(c)
+
+	metrics := newBuildMetrics(nil)
+	client := &http.Client{Transport: tracer.transport(metrics.transport(nil))}
+	state := new(multistep.BasicStateBag)
+	state.Put("config", c)
+	state.Put("instance", &linodego.Instance{ID: 42})
+
+	step := &timedStep{Step: &testMetricsStep{client, api.URL}, metrics: metrics, tracer: tracer}
+	step.Run(context.Background(), state)
+	tracer.endBuild(state)
+
+	if err := tracer.export(context.Background()); err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+
+	data, err := ioutil.ReadFile(c.TracingFile)
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+	var written otlpRequest
+	if err := json.Unmarshal(data, &written); err != nil {
+		t.Fatalf("invalid trace file: %s", err)
+	}
+
+	for _, req := range []otlpRequest{collected, written} {
+		spans := req.ResourceSpans[0].ScopeSpans[0].Spans
+		if len(spans) != 3 {
+			t.Fatalf("expected 3 spans: %#v", spans)
+		}
+		apiSpan, stepSpan, rootSpan := spans[0], spans[1], spans[2]
+		if apiSpan.ParentSpanID != stepSpan.SpanID || stepSpan.ParentSpanID != rootSpan.SpanID || rootSpan.ParentSpanID != "" {
+			t.Errorf("bad span hierarchy: %#v", spans)
+		}
+		if stepSpan.Name != "testMetricsStep" || apiSpan.Name != "GET /linode/instances" {
+			t.Errorf("bad span names: %#v", spans)
+		}
+
+		attributes := make(map[string]map[string]interface{})
+		for _, a := range stepSpan.Attributes {
+			attributes[a.Key] = a.Value
+		}
+		if attributes["linode.instance.id"]["intValue"] != "42" || attributes["linode.region"]["stringValue"] != "us-east" {
+			t.Errorf("bad step attributes: %#v", attributes)
+		}
+
+		attributes = make(map[string]map[string]interface{})
+		for _, a := range apiSpan.Attributes {
+			attributes[a.Key] = a.Value
+		}
+		if attributes["http.status_code"]["intValue"] != "200" {
+			t.Errorf("bad API attributes: %#v", attributes)
+		}
+	}
+}
+
+func TestNewTracer_Traceparent(t *testing.T) {
+	if tracer := newTracer(&Config{}); tracer != nil {
+		t.Fatal("tracing should be disabled by default")
+	}
+
+	old := os.Getenv("TRACEPARENT")
+	defer os.Setenv("TRACEPARENT", old)
+	os.Setenv("TRACEPARENT", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+
+	tracer := newTracer(&Config{TracingFile: "trace.json"})
+	if tracer.traceID != "4bf92f3577b34da6a3ce929d0e0e4736" || tracer.parentID != "00f067aa0ba902b7" {
+		t.Errorf("bad: %s %s", tracer.traceID, tracer.parentID)
+	}
+}
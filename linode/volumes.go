@@ -0,0 +1,174 @@
+package linode
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/hashicorp/packer/packer"
+	"github.com/linode/linodego"
+)
+
+// maxVolumes is how many volumes can be attached along with the disk and the
+// swap disk of the instance.
+const maxVolumes = 6
+
+// VolumeConfig is a Block Storage volume attached to the instance during the
+// build: an existing volume if ID is set, otherwise a temporary volume that is
+// deleted once the build is done.
+type VolumeConfig struct {
+	ID         int    `mapstructure:"id"`
+	Label      string `mapstructure:"label"`
+	Size       int    `mapstructure:"size"`
+	MountPath  string `mapstructure:"mount_path"`
+	Filesystem string `mapstructure:"filesystem"`
+}
+
+var volumeLabelRe = regexp.MustCompile("^[A-Za-z][A-Za-z0-9_-]{0,31}$")
+
+// prepare sets the defaults of the volume and validates it.
+func (v *VolumeConfig) prepare(i int) []error {
+	if v.Filesystem == "" {
+		v.Filesystem = "ext4"
+	}
+
+	var errs []error
+	if v.ID != 0 {
+		if v.Label != "" || v.Size != 0 {
+			errs = append(errs, fmt.Errorf("volumes[%d]: label and size can't be set with id", i))
+		}
+	} else {
+		if v.Size < 10 {
+			errs = append(errs, fmt.Errorf("volumes[%d]: size must be at least 10 GiB", i))
+		}
+		if v.Label != "" && !volumeLabelRe.MatchString(v.Label) {
+			errs = append(errs, fmt.Errorf("volumes[%d]: invalid label: %s", i, v.Label))
+		}
+	}
+	if v.MountPath != "" && (!strings.HasPrefix(v.MountPath, "/") || v.MountPath == "/") {
+		errs = append(errs, fmt.Errorf("volumes[%d]: mount_path must be an absolute path: %s", i, v.MountPath))
+	}
+	switch v.Filesystem {
+	case "ext4", "ext3", "xfs":
+	default:
+		errs = append(errs, fmt.Errorf("volumes[%d]: filesystem must be one of ext4, ext3 or xfs: %s", i, v.Filesystem))
+	}
+	return errs
+}
+
+// mountVolumes reports whether any volume is mounted during the build.
+func (c *Config) mountVolumes() bool {
+	for _, volume := range c.Volumes {
+		if volume.MountPath != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// buildVolume is a volume attached to the instance.
+type buildVolume struct {
+	*linodego.Volume
+	config    VolumeConfig
+	temporary bool
+}
+
+// attachVolumes attaches the volumes of c to the instance, which must be
+// offline, creating the temporary ones. The volumes attached so far are
+// returned along with any error, so that they can be cleaned up.
+func attachVolumes(ctx context.Context, client linodego.Client, c *Config, instance *linodego.Instance) ([]*buildVolume, error) {
+	timeout := int(c.stateTimeout.Seconds())
+	if _, err := client.WaitForInstanceStatus(ctx, instance.ID, linodego.InstanceOffline, timeout); err != nil {
+		return nil, err
+	}
+
+	var volumes []*buildVolume
+	for i, config := range c.Volumes {
+		volume := &buildVolume{config: config, temporary: config.ID == 0}
+
+		if volume.temporary {
+			created, err := client.CreateVolume(ctx, linodego.VolumeCreateOptions{
+				Label:    volumeLabel(config.Label, c.Label, i),
+				Region:   instance.Region,
+				LinodeID: instance.ID,
+				Size:     config.Size,
+				Tags:     c.Tags,
+			})
+			if err != nil {
+				return volumes, fmt.Errorf("unable to create volume: %s", err)
+			}
+			volume.Volume = created
+			volumes = append(volumes, volume)
+		} else {
+			existing, err := client.GetVolume(ctx, config.ID)
+			if err != nil {
+				return volumes, fmt.Errorf("unable to get volume %d: %s", config.ID, err)
+			}
+			if existing.Region != instance.Region {
+				return volumes, fmt.Errorf("volume %d is in %s, not %s", existing.ID, existing.Region, instance.Region)
+			}
+			if existing.LinodeID != nil {
+				return volumes, fmt.Errorf("volume %d is attached to Linode %d", existing.ID, *existing.LinodeID)
+			}
+			if _, err := client.AttachVolume(ctx, existing.ID, &linodego.VolumeAttachOptions{LinodeID: instance.ID}); err != nil {
+				return volumes, fmt.Errorf("unable to attach volume %d: %s", existing.ID, err)
+			}
+			volume.Volume = existing
+			volumes = append(volumes, volume)
+		}
+
+		if _, err := client.WaitForVolumeStatus(ctx, volume.ID, linodego.VolumeActive, timeout); err != nil {
+			return volumes, fmt.Errorf("volume %d is not ready: %s", volume.ID, err)
+		}
+		attached, err := client.WaitForVolumeLinodeID(ctx, volume.ID, &instance.ID, timeout)
+		if err != nil {
+			return volumes, fmt.Errorf("volume %d is not attached: %s", volume.ID, err)
+		}
+		volume.Volume = attached
+	}
+	return volumes, nil
+}
+
+// volumeLabel returns label, or a label derived from the label of the
+// instance for the volume i.
+func volumeLabel(label, instanceLabel string, i int) string {
+	if label != "" {
+		return label
+	}
+	suffix := fmt.Sprintf("-%d", i+1)
+	if len(instanceLabel)+len(suffix) > 32 {
+		instanceLabel = instanceLabel[:32-len(suffix)]
+	}
+	return instanceLabel + suffix
+}
+
+// deleteTemporaryVolumes deletes the temporary volumes once they are detached
+// from the deleted instance.
+func deleteTemporaryVolumes(ctx context.Context, client linodego.Client, c *Config, ui packer.Ui, volumes []*buildVolume) {
+	for _, volume := range volumes {
+		if !volume.temporary {
+			continue
+		}
+		ui.Say(fmt.Sprintf("Deleting volume %s...", volume.Label))
+		if _, err := client.WaitForVolumeLinodeID(ctx, volume.ID, nil, int(c.stateTimeout.Seconds())); err != nil {
+			ui.Error(fmt.Sprintf("Error cleaning up volume %s: %s", volume.Label, err))
+			continue
+		}
+		if err := client.DeleteVolume(ctx, volume.ID); err != nil && !isNotFound(err) {
+			ui.Error(fmt.Sprintf("Error cleaning up volume %s: %s", volume.Label, err))
+		}
+	}
+}
+
+// mountCommand returns the command mounting the volume at its mount path,
+// once its device is there, creating a filesystem if it has none.
+func mountCommand(volume *buildVolume) string {
+	device := shellQuote(volume.FilesystemPath)
+	path := shellQuote(volume.config.MountPath)
+	return fmt.Sprintf(
+		"for i in $(seq 60); do [ -e %s ] && break; sleep 1; done"+
+			" && { blkid %s >/dev/null || mkfs.%s %s; }"+
+			" && mkdir -p %s && mount %s %s",
+		device, device, volume.config.Filesystem, device, path, device, path)
+}
@@ -0,0 +1,60 @@
+package linode
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/linode/linodego"
+)
+
+func TestVolumeConfigPrepare(t *testing.T) {
+	v := &VolumeConfig{Size: 20, MountPath: "/var/cache/apt"}
+	if errs := v.prepare(0); len(errs) > 0 {
+		t.Fatalf("should not have error: %v", errs)
+	}
+	if v.Filesystem != "ext4" {
+		t.Errorf("found %s, expected ext4", v.Filesystem)
+	}
+
+	for _, v := range []*VolumeConfig{
+		{Size: 5},
+		{ID: 42, Size: 20},
+		{Size: 20, Label: "-cache"},
+		{ID: 42, MountPath: "cache"},
+		{ID: 42, Filesystem: "ntfs"},
+	} {
+		if errs := v.prepare(0); len(errs) == 0 {
+			t.Errorf("should have error: %#v", v)
+		}
+	}
+}
+
+func TestVolumeLabel(t *testing.T) {
+	if label := volumeLabel("cache", "packer-123", 0); label != "cache" {
+		t.Errorf("bad label: %s", label)
+	}
+	if label := volumeLabel("", "packer-123", 1); label != "packer-123-2" {
+		t.Errorf("bad label: %s", label)
+	}
+	long := strings.Repeat("a", 32)
+	if label := volumeLabel("", long, 0); label != strings.Repeat("a", 30)+"-1" {
+		t.Errorf("bad label: %s", label)
+	}
+}
+
+func TestMountCommand(t *testing.T) {
+	volume := &buildVolume{
+		Volume: &linodego.Volume{FilesystemPath: "/dev/disk/by-id/scsi-0Linode_Volume_cache"},
+		config: VolumeConfig{MountPath: "/var/cache/apt", Filesystem: "ext4"},
+	}
+
+	command := mountCommand(volume)
+	for _, expected := range []string{
+		"mkfs.ext4 '/dev/disk/by-id/scsi-0Linode_Volume_cache'",
+		"mount '/dev/disk/by-id/scsi-0Linode_Volume_cache' '/var/cache/apt'",
+	} {
+		if !strings.Contains(command, expected) {
+			t.Errorf("%q should contain %q", command, expected)
+		}
+	}
+}